@@ -0,0 +1,59 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Message is a message of a topic or a queue
+type Message struct {
+	Key     string
+	Headers map[string]string
+	Body    []byte
+}
+
+// Driver is a connection to a broker
+type Driver interface {
+	Publish(ctx context.Context, topic string, msg *Message) error
+	// Subscribe receives messages published after the call
+	Subscribe(ctx context.Context, topic string) (Subscription, error)
+	Close() error
+}
+
+type Subscription interface {
+	// Next waits for the next message until ctx is done
+	Next(ctx context.Context) (*Message, error)
+	Close() error
+}
+
+// Factory connects to a broker by url
+type Factory func(url string) (Driver, error)
+
+var (
+	drivers   = map[string]Factory{}
+	mxDrivers sync.Mutex
+)
+
+func init() {
+	Register("kafka", newKafka)
+	Register("nats", newNATS)
+	Register("amqp", newAMQP)
+}
+
+// Register adds a driver available in brokers config
+func Register(name string, f Factory) {
+	mxDrivers.Lock()
+	drivers[name] = f
+	mxDrivers.Unlock()
+}
+
+func Open(driver, url string) (Driver, error) {
+	mxDrivers.Lock()
+	f, ok := drivers[driver]
+	mxDrivers.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown broker driver: %q", driver)
+	}
+	return f(url)
+}
@@ -0,0 +1,140 @@
+package broker
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaDriver connects to brokers by url: host:port[,host:port]
+type kafkaDriver struct {
+	addrs  []string
+	writer *kafka.Writer
+}
+
+func newKafka(url string) (Driver, error) {
+	addrs := strings.Split(strings.TrimPrefix(url, "kafka://"), ",")
+	return &kafkaDriver{
+		addrs: addrs,
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(addrs...),
+			AllowAutoTopicCreation: true,
+			BatchSize:              1,
+		},
+	}, nil
+}
+
+func (d *kafkaDriver) Publish(ctx context.Context, topic string, msg *Message) error {
+	m := kafka.Message{
+		Topic: topic,
+		Key:   []byte(msg.Key),
+		Value: msg.Body,
+	}
+	for k, v := range msg.Headers {
+		m.Headers = append(m.Headers, kafka.Header{Key: k, Value: []byte(v)})
+	}
+	return d.writer.WriteMessages(ctx, m)
+}
+
+// Subscribe reads all partitions of the topic from their current last offsets
+func (d *kafkaDriver) Subscribe(ctx context.Context, topic string) (Subscription, error) {
+	conn, err := kafka.DialContext(ctx, "tcp", d.addrs[0])
+	if err != nil {
+		return nil, err
+	}
+	partitions, err := conn.ReadPartitions(topic)
+	conn.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &kafkaSubscription{
+		messages: make(chan *Message),
+		done:     make(chan struct{}),
+	}
+	for _, p := range partitions {
+		leader, err := kafka.DialLeader(ctx, "tcp", net.JoinHostPort(p.Leader.Host, strconv.Itoa(p.Leader.Port)), topic, p.ID)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		offset, err := leader.ReadLastOffset()
+		leader.Close()
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+
+		r := kafka.NewReader(kafka.ReaderConfig{
+			Brokers:   d.addrs,
+			Topic:     topic,
+			Partition: p.ID,
+		})
+		err = r.SetOffset(offset)
+		if err != nil {
+			r.Close()
+			s.Close()
+			return nil, err
+		}
+		s.readers = append(s.readers, r)
+		go s.read(r)
+	}
+	return s, nil
+}
+
+func (d *kafkaDriver) Close() error {
+	return d.writer.Close()
+}
+
+type kafkaSubscription struct {
+	readers  []*kafka.Reader
+	messages chan *Message
+	done     chan struct{}
+}
+
+func (s *kafkaSubscription) read(r *kafka.Reader) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-s.done
+		cancel()
+	}()
+	for {
+		m, err := r.ReadMessage(ctx)
+		if err != nil {
+			return
+		}
+		msg := &Message{
+			Key:     string(m.Key),
+			Headers: map[string]string{},
+			Body:    m.Value,
+		}
+		for _, h := range m.Headers {
+			msg.Headers[h.Key] = string(h.Value)
+		}
+		select {
+		case s.messages <- msg:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *kafkaSubscription) Next(ctx context.Context) (*Message, error) {
+	select {
+	case msg := <-s.messages:
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *kafkaSubscription) Close() error {
+	close(s.done)
+	for _, r := range s.readers {
+		r.Close()
+	}
+	return nil
+}
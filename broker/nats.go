@@ -0,0 +1,74 @@
+package broker
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsDriver publishes and subscribes on subjects, url: nats://host:port
+type natsDriver struct {
+	conn *nats.Conn
+}
+
+func newNATS(url string) (Driver, error) {
+	conn, err := nats.Connect(url)
+	if err != nil {
+		return nil, err
+	}
+	return &natsDriver{conn}, nil
+}
+
+func (d *natsDriver) Publish(ctx context.Context, subject string, msg *Message) error {
+	m := nats.NewMsg(subject)
+	m.Data = msg.Body
+	for k, v := range msg.Headers {
+		m.Header.Set(k, v)
+	}
+	err := d.conn.PublishMsg(m)
+	if err != nil {
+		return err
+	}
+	return d.conn.FlushWithContext(ctx)
+}
+
+func (d *natsDriver) Subscribe(ctx context.Context, subject string) (Subscription, error) {
+	sub, err := d.conn.SubscribeSync(subject)
+	if err != nil {
+		return nil, err
+	}
+	err = d.conn.FlushWithContext(ctx)
+	if err != nil {
+		sub.Unsubscribe()
+		return nil, err
+	}
+	return &natsSubscription{sub}, nil
+}
+
+func (d *natsDriver) Close() error {
+	d.conn.Close()
+	return nil
+}
+
+type natsSubscription struct {
+	sub *nats.Subscription
+}
+
+func (s *natsSubscription) Next(ctx context.Context) (*Message, error) {
+	m, err := s.sub.NextMsgWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	msg := &Message{
+		Headers: map[string]string{},
+		Body:    m.Data,
+	}
+	for k := range m.Header {
+		msg.Headers[k] = m.Header.Get(k)
+	}
+	return msg, nil
+}
+
+func (s *natsSubscription) Close() error {
+	return s.sub.Unsubscribe()
+}
@@ -3,10 +3,18 @@ package cmp
 import (
 	"bytes"
 	"encoding/json"
-	"microtest/vars"
+	"fmt"
+	"reflect"
+	"sort"
 	"strings"
 
-	"reflect"
+	"github.com/vkd/microtest/vars"
+)
+
+const (
+	ArrayModeOrdered   = "ordered"
+	ArrayModeUnordered = "unordered"
+	ArrayModeContains  = "contains"
 )
 
 type Comparator struct {
@@ -16,15 +24,48 @@ type Comparator struct {
 
 	OverrideVars []string `yaml:"override"`
 
+	// Per-path options, paths look like "$.items[*].id".
+	Arrays map[string]ArrayOptions `yaml:"arrays"`
+	Ignore []string                `yaml:"ignore"`
+	Strict []string                `yaml:"strict"`
+
 	overrideMap map[string]struct{}
 
 	vars vars.Map
 }
 
+type ArrayOptions struct {
+	// MatchBy pairs result and expect items by the value of this field.
+	MatchBy string `yaml:"match_by"`
+	// Mode is one of: ordered, unordered, contains.
+	Mode string `yaml:"mode"`
+}
+
 func (c *Comparator) SetVars(m vars.Map) {
 	c.vars = m
 }
 
+// Validate checks modes of array options
+func (c *Comparator) Validate() error {
+	for _, pattern := range c.arrayPatterns() {
+		switch mode := c.Arrays[pattern].Mode; mode {
+		case "", ArrayModeOrdered, ArrayModeUnordered, ArrayModeContains:
+		default:
+			return fmt.Errorf("unknown mode of array %s: %q (expect: ordered, unordered or contains)", pattern, mode)
+		}
+	}
+	return nil
+}
+
+// scratch returns a copy of the comparator with a copy of variables,
+// it checks pairs of items without adding variables of rejected pairs
+func (c *Comparator) scratch() *Comparator {
+	s := *c
+	s.vars = vars.Map{}
+	s.vars.Merge(c.vars)
+	return &s
+}
+
 func (c *Comparator) CmpBody(r, ex []byte) error {
 	if c.IsRaw {
 		if !bytes.Equal(r, ex) {
@@ -47,6 +88,14 @@ func (c *Comparator) CmpBody(r, ex []byte) error {
 }
 
 func (c *Comparator) Compare(result, expect interface{}) error {
+	return c.compare(RootPath, result, expect)
+}
+
+func (c *Comparator) compare(p string, result, expect interface{}) error {
+	if c.isIgnored(p) {
+		return nil
+	}
+
 	if v, ok := expect.(string); ok && strings.HasPrefix(v, "$") {
 		if c.isOverride(v[1:]) {
 			c.vars.Add(v[1:], result)
@@ -67,9 +116,9 @@ func (c *Comparator) Compare(result, expect interface{}) error {
 	}
 	switch r := result.(type) {
 	case map[string]interface{}:
-		return c.cmpMap(r, expect.(map[string]interface{}))
+		return c.cmpMap(p, r, expect.(map[string]interface{}))
 	case []interface{}:
-		return c.cmpSlice(r, expect.([]interface{}))
+		return c.cmpSliceAt(p, r, expect.([]interface{}))
 	case int:
 		if r != expect.(int) {
 			return NewErrNotEqual(result, expect)
@@ -99,18 +148,26 @@ func (c *Comparator) Compare(result, expect interface{}) error {
 	return nil
 }
 
-func (c *Comparator) cmpMap(result, expect map[string]interface{}) error {
-	if !c.IsLeast && len(result) != len(expect) {
-		return ErrLenMapsNotEquals
+func (c *Comparator) cmpMap(p string, result, expect map[string]interface{}) error {
+	if !c.isLeast(p) {
+		for k := range result {
+			if _, ok := expect[k]; !ok && !c.isIgnored(fieldPath(p, k)) {
+				return ErrLenMapsNotEquals
+			}
+		}
 	}
 	var r interface{}
 	var ok bool
 	var err error
 	for k, v := range expect {
+		fp := fieldPath(p, k)
+		if c.isIgnored(fp) {
+			continue
+		}
 		if r, ok = result[k]; !ok {
 			return NewErrFieldNotFound(k)
 		}
-		err = c.Compare(r, v)
+		err = c.compare(fp, r, v)
 		if err != nil {
 			return NewErrCmpField(k, err)
 		}
@@ -118,73 +175,213 @@ func (c *Comparator) cmpMap(result, expect map[string]interface{}) error {
 	return nil
 }
 
-// func (c *Comparator) cmpSlice(result, expect []interface{}) error {
-// 	if !c.IsLeast && len(result) != len(expect) {
-// 		return ErrLenSlicesNotEqueals
-// 	}
-
-// 	if !c.IsOrdered {
-// 		return c.cmpSliceUnordered(result, expect)
-// 	}
+func (c *Comparator) cmpSlice(result, expect []interface{}) error {
+	return c.cmpSliceAt(RootPath, result, expect)
+}
 
-// 	var err error
-// 	for i, exp := range expect {
-// 		err = c.Compare(result[i], exp)
-// 		if err != nil {
-// 			return NewErrCmpIndex(i, err)
-// 		}
-// 	}
+func (c *Comparator) cmpSliceAt(p string, result, expect []interface{}) error {
+	opts := c.arrayOptions(p)
 
-// 	return nil
-// }
+	isLeast, isOrdered := c.isLeast(p), c.IsOrdered
+	switch opts.Mode {
+	case ArrayModeOrdered:
+		isOrdered = true
+	case ArrayModeUnordered:
+		isOrdered = false
+	case ArrayModeContains:
+		isLeast, isOrdered = true, false
+	case "":
+	default:
+		return fmt.Errorf("unknown mode of array: %q", opts.Mode)
+	}
 
-func (c *Comparator) cmpSlice(result, expect []interface{}) error {
-	if !c.IsLeast && len(result) != len(expect) {
+	if !isLeast && len(result) != len(expect) {
 		return ErrLenSlicesNotEquals
 	}
 
-	var err error
-	var idx int
-	var marks = make([]bool, len(result))
+	if opts.MatchBy != "" {
+		return c.cmpSliceByKey(p, opts.MatchBy, result, expect)
+	}
+	if isOrdered {
+		return c.cmpSliceOrdered(p, result, expect)
+	}
+	return c.cmpSliceUnordered(p, result, expect)
+}
 
-	for ie, exp := range expect {
-		idx = -1
+func (c *Comparator) cmpSliceOrdered(p string, result, expect []interface{}) error {
+	for i, exp := range expect {
+		if i >= len(result) {
+			return ErrExpectNotFoundInArray
+		}
+		err := c.compare(indexPath(p, i), result[i], exp)
+		if err != nil {
+			return NewErrCmpIndex(i, err)
+		}
+	}
+	return nil
+}
 
-		ir := 0
-		if c.IsOrdered {
-			ir = ie
+// cmpSliceUnordered finds a maximum bipartite matching between expect and
+// result items, so a successful pairing is found whenever one exists.
+func (c *Comparator) cmpSliceUnordered(p string, result, expect []interface{}) error {
+	matchedBy, missing := MatchUnordered(len(expect), len(result), func(ie, ir int) bool {
+		return c.scratch().compare(indexPath(p, ir), result[ir], expect[ie]) == nil
+	})
+	if missing != -1 {
+		return NewErrCmpIndex(missing, ErrExpectNotFoundInArray)
+	}
+
+	// compare the chosen pairs again: overridden variables must be taken from them
+	for ir, ie := range matchedBy {
+		if ie == -1 {
+			continue
+		}
+		err := c.compare(indexPath(p, ir), result[ir], expect[ie])
+		if err != nil {
+			return NewErrCmpIndex(ir, err)
 		}
-		for ir < len(result) {
+	}
+	return nil
+}
 
-			res := result[ir]
+// MatchUnordered finds a maximum bipartite matching between expects and results by match,
+// it returns indexes of expects matched by results (-1 for unmatched results)
+// and the index of the first unmatched expect or -1 when all of them are matched
+func MatchUnordered(expects, results int, match func(ie, ir int) bool) (matchedBy []int, missing int) {
+	edges := make([][]int, expects)
+	for ie := range edges {
+		for ir := 0; ir < results; ir++ {
+			if match(ie, ir) {
+				edges[ie] = append(edges[ie], ir)
+			}
+		}
+	}
+
+	matchedBy = make([]int, results)
+	for i := range matchedBy {
+		matchedBy[i] = -1
+	}
 
-			if marks[ir] {
-				ir++
+	var augment func(ie int, seen []bool) bool
+	augment = func(ie int, seen []bool) bool {
+		for _, ir := range edges[ie] {
+			if seen[ir] {
 				continue
 			}
-			err = c.Compare(res, exp)
-			if err == nil {
-				idx = ir
-				break
+			seen[ir] = true
+			if matchedBy[ir] == -1 || augment(matchedBy[ir], seen) {
+				matchedBy[ir] = ie
+				return true
 			}
+		}
+		return false
+	}
+
+	for ie := 0; ie < expects; ie++ {
+		if !augment(ie, make([]bool, results)) {
+			return matchedBy, ie
+		}
+	}
+	return matchedBy, -1
+}
+
+func (c *Comparator) cmpSliceByKey(p, key string, result, expect []interface{}) error {
+	used := make([]bool, len(result))
 
-			if c.IsOrdered {
-				if err != nil {
-					return NewErrCmpIndex(ir, err)
-				}
+	for ie, exp := range expect {
+		expMap, ok := exp.(map[string]interface{})
+		if !ok {
+			return NewErrCmpIndex(ie, NewErrDifferentTypes(map[string]interface{}{}, exp))
+		}
+		expKey, ok := expMap[key]
+		if !ok {
+			return NewErrCmpIndex(ie, NewErrFieldNotFound(key))
+		}
+
+		idx := -1
+		for ir, res := range result {
+			if used[ir] {
+				continue
+			}
+			resMap, ok := res.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			resKey, ok := resMap[key]
+			if !ok {
+				continue
+			}
+			if c.scratch().compare(fieldPath(indexPath(p, ir), key), resKey, expKey) == nil {
+				idx = ir
 				break
 			}
+		}
+		if idx == -1 {
+			return NewErrKeyNotFoundInArray(key, expKey)
+		}
+		used[idx] = true
 
-			ir++
+		err := c.compare(indexPath(p, idx), result[idx], exp)
+		if err != nil {
+			return NewErrCmpIndex(idx, err)
 		}
+	}
+	return nil
+}
 
-		if idx == -1 {
-			return ErrExpectNotFoundInArray
+func (c *Comparator) isIgnored(p string) bool {
+	for _, pattern := range c.Ignore {
+		if full, _ := matchPathSegments(pattern, p); full {
+			return true
 		}
-		marks[idx] = true
 	}
+	return false
+}
 
-	return nil
+func (c *Comparator) isLeast(p string) bool {
+	if !c.IsLeast {
+		return false
+	}
+	for _, pattern := range c.Strict {
+		if _, prefix := matchPathSegments(pattern, p); prefix {
+			return false
+		}
+	}
+	return true
+}
+
+// arrayOptions returns options of the most specific pattern matching the path:
+// the one with less wildcards, the first one in order of patterns for equal ones
+func (c *Comparator) arrayOptions(p string) ArrayOptions {
+	best, bestWildcards := "", -1
+	for _, pattern := range c.arrayPatterns() {
+		if full, _ := matchPathSegments(pattern, p); !full {
+			continue
+		}
+		wildcards := 0
+		for _, seg := range splitPath(pattern) {
+			if seg == "*" || seg == "[*]" {
+				wildcards++
+			}
+		}
+		if bestWildcards == -1 || wildcards < bestWildcards {
+			best, bestWildcards = pattern, wildcards
+		}
+	}
+	if bestWildcards == -1 {
+		return ArrayOptions{}
+	}
+	return c.Arrays[best]
+}
+
+// arrayPatterns returns sorted patterns of array options
+func (c *Comparator) arrayPatterns() []string {
+	patterns := make([]string, 0, len(c.Arrays))
+	for pattern := range c.Arrays {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	return patterns
 }
 
 func (c *Comparator) isOverride(key string) bool {
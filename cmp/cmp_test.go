@@ -128,3 +128,134 @@ func TestComparator_CmpBody(t *testing.T) {
 		})
 	}
 }
+
+func TestComparator_cmpSliceUnorderedMatching(t *testing.T) {
+	c := &Comparator{}
+	// greedy search pairs the first expect item with "mike" and fails on the second one
+	result := []interface{}{
+		map[string]interface{}{"name": "mike", "age": 12.0},
+		map[string]interface{}{"name": "mike", "age": 14.0},
+	}
+	expect := []interface{}{
+		map[string]interface{}{"name": "$any"},
+		map[string]interface{}{"name": "mike", "age": 12.0},
+	}
+	c.OverrideVars = []string{"any"}
+	c.IsLeast = true
+	c.SetVars(map[string]interface{}{})
+	if err := c.cmpSlice(result, expect); err != nil {
+		t.Errorf("Comparator.cmpSlice() error = %v", err)
+	}
+}
+
+func TestComparator_PathOptions(t *testing.T) {
+	items := func(vs ...interface{}) map[string]interface{} {
+		return map[string]interface{}{"items": vs}
+	}
+	item := func(id float64, name string) map[string]interface{} {
+		return map[string]interface{}{"id": id, "name": name}
+	}
+
+	tests := []struct {
+		name    string
+		c       Comparator
+		result  interface{}
+		expect  interface{}
+		wantErr bool
+	}{
+		{"contains", Comparator{Arrays: map[string]ArrayOptions{"$.items": {Mode: ArrayModeContains}}},
+			items(item(1, "a"), item(2, "b"), item(3, "c")),
+			items(item(3, "c"), item(1, "a")),
+			false},
+		{"contains not found", Comparator{Arrays: map[string]ArrayOptions{"$.items": {Mode: ArrayModeContains}}},
+			items(item(1, "a"), item(2, "b")),
+			items(item(3, "c")),
+			true},
+		{"ordered", Comparator{Arrays: map[string]ArrayOptions{"$.items": {Mode: ArrayModeOrdered}}},
+			items(item(1, "a"), item(2, "b")),
+			items(item(2, "b"), item(1, "a")),
+			true},
+		{"match by", Comparator{Arrays: map[string]ArrayOptions{"$.items": {MatchBy: "id"}}},
+			items(item(1, "a"), item(2, "b")),
+			items(item(2, "b"), item(1, "a")),
+			false},
+		{"match by wrong value", Comparator{Arrays: map[string]ArrayOptions{"$.items": {MatchBy: "id"}}},
+			items(item(1, "a"), item(2, "b")),
+			items(item(2, "a"), item(1, "a")),
+			true},
+		{"match by contains", Comparator{Arrays: map[string]ArrayOptions{"$.items": {MatchBy: "id", Mode: ArrayModeContains}}},
+			items(item(1, "a"), item(2, "b")),
+			items(item(2, "b")),
+			false},
+		{"most specific pattern", Comparator{Arrays: map[string]ArrayOptions{"$.items": {Mode: ArrayModeOrdered}, "$.*": {Mode: ArrayModeUnordered}}},
+			items(item(1, "a"), item(2, "b")),
+			items(item(2, "b"), item(1, "a")),
+			true},
+		{"unknown mode", Comparator{Arrays: map[string]ArrayOptions{"$.items": {Mode: "any"}}},
+			items(item(1, "a")),
+			items(item(1, "a")),
+			true},
+		{"ignore", Comparator{Ignore: []string{"$.meta.*"}},
+			map[string]interface{}{"meta": map[string]interface{}{"ts": 1.0}, "ok": "yes"},
+			map[string]interface{}{"meta": map[string]interface{}{"ts": 2.0}, "ok": "yes"},
+			false},
+		{"ignore in items", Comparator{Ignore: []string{"$.items[*].name"}},
+			items(item(1, "a")),
+			items(item(1, "b")),
+			false},
+		{"ignore missing field", Comparator{Ignore: []string{"$.ts"}},
+			map[string]interface{}{"ok": "yes"},
+			map[string]interface{}{"ok": "yes", "ts": 1.0},
+			false},
+		{"strict", Comparator{IsLeast: true, Strict: []string{"$.user"}},
+			map[string]interface{}{"user": map[string]interface{}{"id": 1.0, "name": "a"}, "extra": "yes"},
+			map[string]interface{}{"user": map[string]interface{}{"id": 1.0}},
+			true},
+		{"not strict", Comparator{IsLeast: true, Strict: []string{"$.user"}},
+			map[string]interface{}{"user": map[string]interface{}{"id": 1.0}, "extra": "yes"},
+			map[string]interface{}{"user": map[string]interface{}{"id": 1.0}},
+			false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.c.Compare(tt.result, tt.expect); (err != nil) != tt.wantErr {
+				t.Errorf("Comparator.Compare() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestComparator_cmpSliceUnorderedVars(t *testing.T) {
+	c := &Comparator{IsLeast: true, OverrideVars: []string{"id"}}
+	vs := map[string]interface{}{}
+	c.SetVars(vs)
+
+	// rejected pairs must not leave variables
+	result := []interface{}{map[string]interface{}{"id": 1.0, "name": "a"}}
+	expect := []interface{}{map[string]interface{}{"id": "$id", "name": "b"}}
+	if err := c.cmpSlice(result, expect); err == nil {
+		t.Fatalf("Comparator.cmpSlice() error = nil")
+	}
+	if _, ok := vs["id"]; ok {
+		t.Errorf("vars = %v, want no id of the rejected pair", vs)
+	}
+
+	result = append(result, map[string]interface{}{"id": 2.0, "name": "b"})
+	if err := c.cmpSlice(result, expect); err != nil {
+		t.Fatalf("Comparator.cmpSlice() error = %v", err)
+	}
+	if vs["id"] != 2.0 {
+		t.Errorf("vars = %v, want id of the matched pair", vs)
+	}
+}
+
+func TestComparator_Validate(t *testing.T) {
+	c := &Comparator{Arrays: map[string]ArrayOptions{"$.items": {Mode: ArrayModeContains}}}
+	if err := c.Validate(); err != nil {
+		t.Errorf("Comparator.Validate() error = %v", err)
+	}
+	c.Arrays["$.tags"] = ArrayOptions{Mode: "any"}
+	if err := c.Validate(); err == nil {
+		t.Errorf("Comparator.Validate() with unknown mode error = nil")
+	}
+}
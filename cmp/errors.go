@@ -90,3 +90,16 @@ func NewErrFieldNotFound(f string) *ErrFieldNotFound {
 func (e *ErrFieldNotFound) Error() string {
 	return fmt.Sprintf("field (%s) not found", e.Field)
 }
+
+type ErrKeyNotFoundInArray struct {
+	Key   string
+	Value interface{}
+}
+
+func NewErrKeyNotFoundInArray(key string, value interface{}) *ErrKeyNotFoundInArray {
+	return &ErrKeyNotFoundInArray{key, value}
+}
+
+func (e *ErrKeyNotFoundInArray) Error() string {
+	return fmt.Sprintf("item with %s=%v not found in array", e.Key, e.Value)
+}
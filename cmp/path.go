@@ -0,0 +1,70 @@
+package cmp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RootPath is the path of the compared document itself.
+const RootPath = "$"
+
+func fieldPath(p, field string) string {
+	return p + "." + field
+}
+
+func indexPath(p string, index int) string {
+	return fmt.Sprintf("%s[%d]", p, index)
+}
+
+// splitPath splits "$.items[0].id" into ["$", "items", "[0]", "id"].
+func splitPath(p string) []string {
+	var segs []string
+	for _, part := range strings.Split(p, ".") {
+		for {
+			i := strings.Index(part, "[")
+			if i < 0 {
+				break
+			}
+			if i > 0 {
+				segs = append(segs, part[:i])
+			}
+			j := strings.Index(part[i:], "]")
+			if j < 0 {
+				break
+			}
+			segs = append(segs, part[i:i+j+1])
+			part = part[i+j+1:]
+		}
+		if part != "" {
+			segs = append(segs, part)
+		}
+	}
+	return segs
+}
+
+// matchPathSegments reports how the pattern relates to the path:
+// full is true when the pattern matches the path exactly,
+// prefix is true when the pattern matches the path or one of its ancestors.
+// A "*" pattern segment matches any field, a "[*]" segment matches any index.
+func matchPathSegments(pattern, p string) (full, prefix bool) {
+	ps, ss := splitPath(pattern), splitPath(p)
+	if len(ps) > len(ss) {
+		return false, false
+	}
+	for i, seg := range ps {
+		if !matchSegment(seg, ss[i]) {
+			return false, false
+		}
+	}
+	return len(ps) == len(ss), true
+}
+
+func matchSegment(pattern, seg string) bool {
+	switch pattern {
+	case "*":
+		return !strings.HasPrefix(seg, "[")
+	case "[*]":
+		return strings.HasPrefix(seg, "[")
+	}
+	return pattern == seg
+}
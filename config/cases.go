@@ -0,0 +1,313 @@
+package config
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// caseRef is {{.case.<field>}} in strings of a test with cases
+var caseRef = regexp.MustCompile(`\{\{\s*\.case\.(\w+)\s*\}\}`)
+
+// CasesConfig are rows of a table test: an inline list or a file,
+// the test is repeated for every row with {{.case.<field>}} replaced by its values
+type CasesConfig struct {
+	// File is .csv (the first row is a header) or .json (an array of objects)
+	File string
+	Rows []map[string]interface{}
+}
+
+func (c *CasesConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if unmarshal(&c.File) == nil {
+		return nil
+	}
+	return unmarshal(&c.Rows)
+}
+
+// load returns rows of cases, the file is relative to dir
+func (c *CasesConfig) load(dir string) ([]map[string]interface{}, error) {
+	if c.File == "" {
+		return c.Rows, nil
+	}
+
+	path := c.File
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return readCasesCSV(path)
+	case ".json":
+		fl, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var rows []map[string]interface{}
+		err = json.Unmarshal(fl, &rows)
+		if err != nil {
+			return nil, fmt.Errorf("parse cases (%s): %v", c.File, err)
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("unknown format of cases (%s): expected .csv or .json", c.File)
+}
+
+func readCasesCSV(path string) ([]map[string]interface{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parse cases (%s): %v", path, err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	var rows []map[string]interface{}
+	for _, rec := range records[1:] {
+		row := map[string]interface{}{}
+		for i, name := range header {
+			row[strings.TrimSpace(name)] = rec[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// casesFiles returns files of cases of tests, relative ones are joined with dir
+func casesFiles(fl []byte, dir string) []string {
+	var conf struct {
+		Tests []struct {
+			Cases interface{} `yaml:"cases"`
+		} `yaml:"tests"`
+	}
+	if yamlv3.Unmarshal(fl, &conf) != nil {
+		return nil
+	}
+	var out []string
+	for _, t := range conf.Tests {
+		if file, ok := t.Cases.(string); ok && file != "" {
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(dir, file)
+			}
+			out = append(out, file)
+		}
+	}
+	return out
+}
+
+// expandCases replaces every test with cases by a test per case named "<name> [<case>]",
+// a case is named by its "name" field or by its number
+func expandCases(root *yamlv3.Node, dir string, onErr func(*yamlv3.Node, error)) bool {
+	tests := mappingValue(root, "tests")
+	if tests == nil || tests.Kind != yamlv3.SequenceNode {
+		return false
+	}
+
+	changed := false
+	var out []*yamlv3.Node
+	for _, t := range tests.Content {
+		casesNode := mappingValue(t, "cases")
+		if casesNode == nil {
+			out = append(out, t)
+			continue
+		}
+		changed = true
+
+		var cc CasesConfig
+		err := casesNode.Decode(&cc)
+		if err == nil && cc.File == "" && cc.Rows == nil && casesNode.Tag != "!!null" {
+			err = fmt.Errorf("cases are a list or a .csv/.json file")
+		}
+		var rows []map[string]interface{}
+		if err == nil {
+			rows, err = cc.load(dir)
+		}
+		if err != nil {
+			onErr(casesNode, err)
+			continue
+		}
+
+		for i, row := range rows {
+			c := copyNode(t)
+			deleteMappingKey(c, "cases")
+			substituteCase(c, row, onErr)
+
+			caseName := fmt.Sprintf("#%d", i+1)
+			if name, ok := row["name"]; ok {
+				caseName = fmt.Sprint(name)
+			}
+			if name := mappingValue(c, "name"); name != nil {
+				name.Value = fmt.Sprintf("%s [%s]", name.Value, caseName)
+			} else {
+				c.Content = append([]*yamlv3.Node{
+					{Kind: yamlv3.ScalarNode, Value: "name", Line: t.Line, Column: t.Column},
+					{Kind: yamlv3.ScalarNode, Value: caseName, Line: t.Line, Column: t.Column},
+				}, c.Content...)
+			}
+			out = append(out, c)
+		}
+	}
+	tests.Content = out
+	return changed
+}
+
+// substituteCase replaces {{.case.<field>}} in values of the node,
+// a value which is only the reference to a number or a bool gets its type (status: "{{.case.status}}" is an int),
+// other values keep their style, so quoted ones stay strings
+func substituteCase(n *yamlv3.Node, row map[string]interface{}, onErr func(*yamlv3.Node, error)) {
+	switch n.Kind {
+	case yamlv3.ScalarNode:
+		loc := caseRef.FindStringSubmatchIndex(n.Value)
+		if loc == nil {
+			return
+		}
+		typed := loc[0] == 0 && loc[1] == len(n.Value) && isTypedValue(row[n.Value[loc[2]:loc[3]]])
+
+		// values inside strings of json bodies are escaped: body: '{"name": "{{.case.name}}"}'
+		trimmed := strings.TrimSpace(n.Value)
+		isJSON := strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")
+
+		var errMissing error
+		var out strings.Builder
+		last := 0
+		for _, loc := range caseRef.FindAllStringSubmatchIndex(n.Value, -1) {
+			out.WriteString(n.Value[last:loc[0]])
+			last = loc[1]
+
+			field := n.Value[loc[2]:loc[3]]
+			v, ok := row[field]
+			if !ok {
+				errMissing = fmt.Errorf("unknown field of case: %s", field)
+				out.WriteString(n.Value[loc[0]:loc[1]])
+				continue
+			}
+			value := caseValue(v)
+			if isJSON && inJSONString(n.Value[:loc[0]]) {
+				value = jsonEscape(value)
+			}
+			out.WriteString(value)
+		}
+		out.WriteString(n.Value[last:])
+		n.Value = out.String()
+		if errMissing != nil {
+			onErr(n, errMissing)
+		}
+		if typed {
+			n.Style, n.Tag = 0, ""
+		}
+	case yamlv3.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			substituteCase(n.Content[i], row, onErr)
+		}
+	default:
+		for _, c := range n.Content {
+			substituteCase(c, row, onErr)
+		}
+	}
+}
+
+// isTypedValue reports whether the field of case is a number or a bool,
+// values of csv are strings, so they are resolved like yaml values
+func isTypedValue(v interface{}) bool {
+	switch v := v.(type) {
+	case bool, int, int64, uint64, float64:
+		return true
+	case string:
+		var out interface{}
+		if yamlv3.Unmarshal([]byte(v), &out) != nil {
+			return false
+		}
+		switch out.(type) {
+		case bool, int, int64, uint64, float64:
+			return true
+		}
+	}
+	return false
+}
+
+// inJSONString reports whether the end of the json prefix is inside a string
+func inJSONString(prefix string) bool {
+	in := false
+	for i := 0; i < len(prefix); i++ {
+		switch {
+		case in && prefix[i] == '\\':
+			i++
+		case prefix[i] == '"':
+			in = !in
+		}
+	}
+	return in
+}
+
+// jsonEscape escapes the value to be put into a json string
+func jsonEscape(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if enc.Encode(s) != nil {
+		return s
+	}
+	out := strings.TrimSuffix(buf.String(), "\n")
+	return out[1 : len(out)-1]
+}
+
+// caseValue formats a field of case, lists and objects are json
+func caseValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		bs, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(bs)
+	}
+	return fmt.Sprint(v)
+}
+
+func mappingValue(n *yamlv3.Node, key string) *yamlv3.Node {
+	if n == nil || n.Kind != yamlv3.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func deleteMappingKey(n *yamlv3.Node, key string) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			n.Content = append(n.Content[:i:i], n.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+func copyNode(n *yamlv3.Node) *yamlv3.Node {
+	c := *n
+	c.Content = make([]*yamlv3.Node, len(n.Content))
+	for i, child := range n.Content {
+		c.Content[i] = copyNode(child)
+	}
+	return &c
+}
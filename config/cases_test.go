@@ -0,0 +1,90 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRead_Cases(t *testing.T) {
+	conf, err := Read("testdata/cases/cases.yaml", nil)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	var names []string
+	for _, tc := range conf.Tests {
+		names = append(names, tc.Name)
+		if tc.Cases != nil {
+			t.Errorf("cases of %q are not expanded", tc.Name)
+		}
+	}
+	wantNames := []string{"get user [admin]", "get user [guest]", "create user [#1]", "create user [#2]", "delete user [#1]"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Fatalf("names = %q, want %q", names, wantNames)
+	}
+
+	tests := conf.Tests
+	if tests[1].Request.URL != "/users/2" || tests[1].Expect.BodyMin != `{"id": 2, "role": "guest"}` {
+		t.Errorf("guest = %q, %q", tests[1].Request.URL, tests[1].Expect.BodyMin)
+	}
+	if tests[2].Request.Body != `{"name": "Mike"}` || tests[2].Expect.Status != 201 {
+		t.Errorf("csv case = %q, %d", tests[2].Request.Body, tests[2].Expect.Status)
+	}
+	if tests[3].Request.Body != `{}` || tests[3].Expect.Status != 400 {
+		t.Errorf("csv case = %q, %d", tests[3].Request.Body, tests[3].Expect.Status)
+	}
+	stubs := tests[4].Mocks["billing"].Stubs
+	if tests[4].Request.URL != "/users/5" || len(stubs) != 1 || stubs[0].URL != "/accounts/5" || stubs[0].Out != `{"balance":10}` {
+		t.Errorf("json case = %q, %+v", tests[4].Request.URL, stubs)
+	}
+}
+
+func TestValidate_Cases(t *testing.T) {
+	if problems := Validate("testdata/cases/cases.yaml", nil); len(problems) > 0 {
+		t.Errorf("Validate() = %v", problems)
+	}
+
+	var msgs []string
+	for _, p := range Validate("testdata/cases/unknown_field.yaml", nil) {
+		msgs = append(msgs, p.Error())
+	}
+	want := []string{"testdata/cases/unknown_field.yaml:9:12: unknown field of case: user_id"}
+	if !reflect.DeepEqual(msgs, want) {
+		t.Errorf("Validate() = %q, want %q", msgs, want)
+	}
+}
+
+func TestRead_CasesStrings(t *testing.T) {
+	conf, err := Read("testdata/cases/strings.yaml", nil)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	want := map[string]string{"X-Null": "null", "X-Tilde": "~", "X-Empty": "", "X-Id": "7"}
+	if got := conf.Tests[0].Request.Headers; !reflect.DeepEqual(got, want) {
+		t.Errorf("headers = %q, want %q", got, want)
+	}
+}
+
+func TestRead_CasesExtends(t *testing.T) {
+	conf, err := Read("testdata/cases/suites/extends.yaml", nil)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(conf.Tests) != 2 || conf.Tests[0].Expect.Status != 201 || conf.Tests[1].Expect.Status != 400 {
+		t.Errorf("tests = %+v", conf.Tests)
+	}
+}
+
+func TestRead_CasesJSONEscape(t *testing.T) {
+	conf, err := Read("testdata/cases/quotes.yaml", nil)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	r := conf.Tests[0].Request
+	if want := `{"name": "Mike \"the\" \\ Kid", "title": "Dr"}`; r.Body != want {
+		t.Errorf("body = %s, want %s", r.Body, want)
+	}
+	if want := `Mike "the" \ Kid`; r.Headers["X-Name"] != want {
+		t.Errorf("header = %s, want %s", r.Headers["X-Name"], want)
+	}
+}
@@ -0,0 +1,684 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/vkd/microtest/cmp"
+	"github.com/vkd/microtest/duration"
+	"github.com/vkd/microtest/retry"
+	"github.com/vkd/microtest/schema"
+	"github.com/vkd/microtest/vars"
+)
+
+type Config struct {
+	Name    string `yaml:"name"`
+	Image   string `yaml:"image"`
+	Command string `yaml:"command"`
+
+	// Tags of all tests of the file
+	Tags []string `yaml:"tags"`
+
+	// Secrets are names of variables which values are masked in log output and records
+	Secrets []string `yaml:"secrets"`
+
+	Port int `yaml:"port"`
+	// GRPCPort is a port of gRPC requests (default: port)
+	GRPCPort int `yaml:"grpc_port"`
+
+	// Proto is a .proto file or a descriptor set of gRPC services of the tested service
+	Proto string `yaml:"proto"`
+
+	// OpenAPI spec of the tested service, responses are checked by it
+	OpenAPI string `yaml:"openapi"`
+
+	PingRequest PingRequestConfig `yaml:"ping_request"`
+	// Sleep duration.StringDuration `yaml:"sleep"`
+
+	// // MockServices []string `yaml:"mockservices"`
+
+	Mocks MockConfigs `yaml:"mocks"`
+	// FailOnUnmatched fails a test when any request to mocks has no matched stub
+	FailOnUnmatched bool `yaml:"fail_on_unmatched"`
+
+	// ProtocolMocks are mocks of non-HTTP upstreams, each listens on its own port
+	ProtocolMocks map[string]ProtocolMockConfig `yaml:"protocol_mocks"`
+
+	Services []ServiceConfig `yaml:"services"`
+
+	// Brokers are connections to message brokers, usually started in services
+	Brokers map[string]BrokerConfig `yaml:"brokers"`
+
+	// BeforeEach and AfterEach are steps of every test, after_each steps run on failed tests too
+	BeforeEach []StepConfig `yaml:"before_each"`
+	AfterEach  []StepConfig `yaml:"after_each"`
+
+	Tests []TestConfig `yaml:"tests"`
+
+	Record RecordConfig `yaml:"record"`
+
+	// files are the config, its base files and files of cases
+	files []string
+	// secretValues are values of variables of Secrets
+	secretValues []string
+}
+
+type RecordConfig struct {
+	Redact RedactConfig `yaml:"redact"`
+}
+
+// RedactConfig lists response headers and json fields which values are not written to records
+type RedactConfig struct {
+	Headers []string `yaml:"headers"`
+	Fields  []string `yaml:"fields"`
+}
+
+type TestConfig struct {
+	Name string   `yaml:"name"`
+	Tags []string `yaml:"tags"`
+	// Skip is a reason of skipping the test
+	Skip string `yaml:"skip"`
+	// Only skips other tests of the file
+	Only bool `yaml:"only"`
+
+	Sleep   int              `yaml:"sleep"`
+	Request RequestConfig    `yaml:"request"`
+	Mocks   MockConfigs      `yaml:"mocks"`
+	Expect  ExpectMockConfig `yaml:"expect"`
+
+	// Publish are messages sent to brokers before the request
+	Publish []PublishConfig `yaml:"publish"`
+
+	// Steps are run in order before the request of the test (if any)
+	Steps []StepConfig `yaml:"steps"`
+
+	// Cases repeat the test for every row, they are expanded by Read
+	Cases *CasesConfig `yaml:"cases"`
+
+	vars map[string]interface{}
+}
+
+// StepConfig is one of: request (with expect and publish), sleep, wait, exec or reset_mocks
+type StepConfig struct {
+	Name string `yaml:"name"`
+
+	Request *RequestConfig   `yaml:"request"`
+	Publish []PublishConfig  `yaml:"publish"`
+	Expect  ExpectMockConfig `yaml:"expect"`
+
+	Sleep duration.StringDuration `yaml:"sleep"`
+	Wait  *WaitConfig             `yaml:"wait"`
+	Exec  *ExecConfig             `yaml:"exec"`
+
+	// ResetMocks clears requests received by mocks
+	ResetMocks bool `yaml:"reset_mocks"`
+}
+
+// Kind returns the name of the step action
+func (s *StepConfig) Kind() string {
+	switch {
+	case s.Request != nil:
+		method := s.Request.Method
+		if method == "" {
+			method = "GET"
+		}
+		return method + " " + s.Request.URL
+	case s.Wait != nil:
+		return "wait " + s.Wait.Request.URL
+	case s.Exec != nil:
+		return "exec " + s.Exec.Command
+	case s.ResetMocks:
+		return "reset mocks"
+	case s.Sleep > 0:
+		return "sleep " + time.Duration(s.Sleep).String()
+	}
+	return "publish"
+}
+
+// WaitConfig repeats the request until the expect is passed,
+// timeout is 30s and interval is 1s by default
+type WaitConfig struct {
+	Request      RequestConfig `yaml:"request"`
+	Expect       ExpectConfig  `yaml:"expect"`
+	retry.Config `yaml:",inline"`
+}
+
+// ExecConfig runs the command by `sh -c` in the tested service or in the service
+type ExecConfig struct {
+	Service  string `yaml:"service"`
+	Command  string `yaml:"command"`
+	ExitCode int    `yaml:"exit_code"`
+}
+
+const (
+	ProtocolHTTP      = "http"
+	ProtocolGRPC      = "grpc"
+	ProtocolWebSocket = "websocket"
+	ProtocolSSE       = "sse"
+)
+
+type RequestConfig struct {
+	// Protocol is http (default), grpc, websocket or sse,
+	// url of gRPC request is a method: pkg.Service/Method
+	Protocol string `yaml:"protocol"`
+
+	URL     string                  `yaml:"url"`
+	Method  string                  `yaml:"method"`
+	Headers map[string]string       `yaml:"headers"`
+	Timeout duration.StringDuration `yaml:"timeout"`
+	Body    string                  `yaml:"body"`
+	Expect  *ExpectConfig           `yaml:"expect"`
+
+	// Send are messages sent to websocket after the connection
+	Send []string `yaml:"send"`
+}
+
+func (r *RequestConfig) OverrideByVariables(vs vars.Map) {
+	for k, v := range vs {
+		if vStr, ok := v.(string); ok {
+			r.URL = strings.Replace(r.URL, "{"+k+"}", vStr, -1)
+		}
+	}
+}
+
+type PingRequestConfig struct {
+	RequestConfig `yaml:",inline"`
+	Count         int `yaml:"count"`
+}
+
+type ExpectConfig struct {
+	cmp.Comparator `yaml:",inline"`
+	Status         int `yaml:"status"`
+	// GRPCStatus is a code of gRPC response: OK (default), NOT_FOUND, ...
+	GRPCStatus string        `yaml:"grpc_status"`
+	Body       string        `yaml:"body"`
+	BodyMin    string        `yaml:"body_min"`
+	Schema     *SchemaConfig `yaml:"schema"`
+
+	// Stream are messages received by websocket and events of sse
+	Stream *StreamExpectConfig `yaml:"stream"`
+
+	// Retry re-sends the request (re-checks requests of mocks) until the expect is passed
+	Retry *retry.Config `yaml:"retry"`
+}
+
+// SetVars sets variables of body comparators including ones of stream messages
+func (e *ExpectConfig) SetVars(vs vars.Map) {
+	e.Comparator.SetVars(vs)
+	if e.Stream != nil {
+		for i := range e.Stream.Messages {
+			e.Stream.Messages[i].SetVars(vs)
+		}
+	}
+}
+
+func (e *ExpectConfig) resolvePaths(dir string) {
+	if e == nil {
+		return
+	}
+	e.Schema.resolvePath(dir)
+	if e.Stream != nil {
+		for i := range e.Stream.Messages {
+			e.Stream.Messages[i].resolvePaths(dir)
+		}
+	}
+}
+
+const (
+	StreamOrdered   = "ordered"
+	StreamUnordered = "unordered"
+)
+
+type StreamExpectConfig struct {
+	// Mode is ordered (default) or unordered
+	Mode string `yaml:"mode"`
+	// Timeout of every message (default: timeout of request or 3s)
+	Timeout  duration.StringDuration `yaml:"timeout"`
+	Messages []StreamMessageConfig   `yaml:"messages"`
+}
+
+type StreamMessageConfig struct {
+	ExpectConfig `yaml:",inline"`
+	// Event is a name of sse event
+	Event   string                  `yaml:"event"`
+	Timeout duration.StringDuration `yaml:"timeout"`
+	// Send is a websocket message sent before waiting for the message
+	Send string `yaml:"send"`
+}
+
+// SchemaConfig is an inline JSON Schema or a path to the schema file
+type SchemaConfig struct {
+	Path   string
+	Inline []byte
+}
+
+func (s *SchemaConfig) UnmarshalYAML(f func(interface{}) error) error {
+	var v interface{}
+	err := f(&v)
+	if err != nil {
+		return err
+	}
+
+	if str, ok := v.(string); ok {
+		if strings.HasPrefix(strings.TrimSpace(str), "{") {
+			s.Inline = []byte(str)
+		} else {
+			s.Path = str
+		}
+		return nil
+	}
+
+	s.Inline, err = json.Marshal(jsonCompatible(v))
+	return err
+}
+
+func (s *SchemaConfig) Load() (*schema.Schema, error) {
+	if s.Path != "" {
+		return schema.CompileFile(s.Path)
+	}
+	return schema.Compile(s.Inline)
+}
+
+func (s *SchemaConfig) resolvePath(dir string) {
+	if s != nil {
+		resolvePath(&s.Path, dir)
+	}
+}
+
+// jsonCompatible converts maps decoded by yaml to maps with string keys
+func jsonCompatible(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[fmt.Sprint(k)] = jsonCompatible(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range v {
+			v[i] = jsonCompatible(val)
+		}
+	}
+	return v
+}
+
+type ExpectMockConfig struct {
+	ExpectConfig `yaml:",inline"`
+
+	Mocks map[string]ExpectConfig `yaml:"mocks"`
+
+	// Messages are expected to be published to brokers after the request
+	Messages []MessageExpectConfig `yaml:"messages"`
+}
+
+type BrokerConfig struct {
+	// Driver is kafka, nats or amqp (other ones are added by broker.Register)
+	Driver string `yaml:"driver"`
+	// URL of the broker, its host may be a name of a service
+	URL string `yaml:"url"`
+}
+
+// PublishConfig is a message of a topic (subject of nats, "queue" or "exchange/routing_key" of amqp)
+type PublishConfig struct {
+	Broker  string            `yaml:"broker"`
+	Topic   string            `yaml:"topic"`
+	Key     string            `yaml:"key"`
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"`
+}
+
+// MessageExpectConfig waits for a message of the topic matched by body (or body_min)
+type MessageExpectConfig struct {
+	ExpectConfig `yaml:",inline"`
+
+	Broker string `yaml:"broker"`
+	Topic  string `yaml:"topic"`
+	Key    string `yaml:"key"`
+	// Timeout of waiting (default: 3s)
+	Timeout duration.StringDuration `yaml:"timeout"`
+}
+
+type MockConfigs map[string]MockHostConfig
+
+// MockHostConfig is a list of stubs or a mapping with host options
+type MockHostConfig struct {
+	// OpenAPI spec of the upstream, incoming requests are checked by it
+	// and examples of its operations are served when no stub matches
+	OpenAPI string       `yaml:"openapi"`
+	Stubs   []MockConfig `yaml:"stubs"`
+
+	// Upstream is a base url of the real service, requests are proxied to it by `microtest record`
+	Upstream string `yaml:"upstream"`
+	// Replay is a file with recorded stubs (default: <host>.recorded.yaml for hosts with upstream)
+	Replay string `yaml:"replay"`
+
+	Fallback MockFallbackConfig `yaml:"fallback"`
+
+	// replayed is a count of recorded stubs at the end of Stubs
+	replayed int
+}
+
+const (
+	FallbackStrict  = "strict"
+	FallbackProxy   = "proxy"
+	FallbackDefault = "default"
+)
+
+// MockFallbackConfig is a policy for requests without matched stubs:
+// strict fails the test, proxy sends the request to URL, default returns the response
+type MockFallbackConfig struct {
+	Mode string `yaml:"mode"`
+
+	URL string `yaml:"url"`
+
+	Status  int               `yaml:"status"`
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"`
+}
+
+func (f *MockFallbackConfig) UnmarshalYAML(fn func(interface{}) error) error {
+	var mode string
+	if err := fn(&mode); err == nil {
+		f.Mode = mode
+	} else {
+		type plain MockFallbackConfig
+		err = fn((*plain)(f))
+		if err != nil {
+			return err
+		}
+		if f.Mode == "" {
+			f.Mode = FallbackDefault
+			if f.URL != "" {
+				f.Mode = FallbackProxy
+			}
+		}
+	}
+
+	switch f.Mode {
+	case FallbackProxy:
+		if f.URL == "" {
+			return fmt.Errorf("'url' of proxy mock fallback not found")
+		}
+		return nil
+	case FallbackStrict, FallbackDefault:
+		return nil
+	}
+	return fmt.Errorf("unknown mock fallback mode: %q", f.Mode)
+}
+
+func (h *MockHostConfig) UnmarshalYAML(f func(interface{}) error) error {
+	var stubs []MockConfig
+	if err := f(&stubs); err == nil {
+		h.Stubs = stubs
+		return nil
+	}
+
+	type plain MockHostConfig
+	return f((*plain)(h))
+}
+
+type MockConfig struct {
+	Method string `yaml:"method,omitempty"`
+	URL    string `yaml:"url,omitempty"`
+	// Request struct {
+	// 	cmp.Comparator
+	// 	Body string `json:"body"`
+	// } `yaml:"request"`
+	Status  int               `yaml:"status,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Out     string            `yaml:"body,omitempty"`
+
+	Fault *MockFaultConfig `yaml:"fault,omitempty"`
+
+	// index int
+}
+
+// MockFaultConfig simulates failures of an upstream
+type MockFaultConfig struct {
+	// Delay before the response, random in [delay, delay_max] when delay_max is set
+	Delay    duration.StringDuration `yaml:"delay"`
+	DelayMax duration.StringDuration `yaml:"delay_max"`
+
+	// ErrorRate is a probability (0..1) to respond with ErrorStatus (default: 503)
+	ErrorRate   float64 `yaml:"error_rate"`
+	ErrorStatus int     `yaml:"error_status"`
+
+	// Reset closes the connection without a response
+	Reset bool `yaml:"reset"`
+	// CloseBody closes the connection after a half of the body
+	CloseBody bool `yaml:"close_body"`
+
+	// Drip sends the body by DripSize bytes (default: 1) with the delay between chunks
+	Drip     duration.StringDuration `yaml:"drip"`
+	DripSize int                     `yaml:"drip_size"`
+}
+
+func (m *MockConfig) String() string {
+	return fmt.Sprintf("Method: %q, Url: %q, Status: %d, Out: %q", m.Method, m.URL, m.Status, m.Out)
+}
+
+const (
+	ProtocolSMTP = "smtp"
+	ProtocolTCP  = "tcp"
+)
+
+type ProtocolMockConfig struct {
+	Protocol string `yaml:"protocol"`
+	Port     int    `yaml:"port"`
+
+	// Greeting is sent to new connections of tcp mock
+	Greeting string `yaml:"greeting"`
+	// Script answers received lines of tcp mock
+	Script []TCPScriptStepConfig `yaml:"script"`
+
+	// Username and Password are required by AUTH of smtp mock, any credentials are accepted when they are empty
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+
+	// Proto is a .proto file or a descriptor set of services of grpc mock
+	Proto string `yaml:"proto"`
+	// Methods are responses of grpc mock by method: pkg.Service/Method
+	Methods map[string]GRPCMethodConfig `yaml:"methods"`
+}
+
+// GRPCMethodConfig is a json encoded response or an error status with the message
+type GRPCMethodConfig struct {
+	Body    string `yaml:"body"`
+	Status  string `yaml:"status"`
+	Message string `yaml:"message"`
+}
+
+// TCPScriptStepConfig sends a line on a received line equal to Expect
+// (a regexp with "~" prefix, any line when empty)
+type TCPScriptStepConfig struct {
+	Expect string `yaml:"expect"`
+	Send   string `yaml:"send"`
+	Close  bool   `yaml:"close"`
+}
+
+type ServiceConfig struct {
+	Image string    `yaml:"image"`
+	Env   EnvConfig `yaml:"env"`
+
+	ReadyTimeoutSec int `yaml:"ready_timeout"`
+}
+
+type EnvConfig map[string]string
+
+func (e EnvConfig) Slice() []string {
+	res := make([]string, 0, len(e))
+	for k, v := range e {
+		res = append(res, fmt.Sprintf("%s:%s", k, v))
+	}
+	return res
+}
+
+// Read reads the config with extends, variables of env and cases expanded,
+// recorded stubs of mocks are loaded by LoadReplays
+func Read(path string, env *Env) (*Config, error) {
+	fl, files, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, casesFiles(fl, filepath.Dir(path))...)
+
+	fl, err = expandConfig(fl, filepath.Dir(path), env)
+	if err != nil {
+		return nil, err
+	}
+
+	conf := &Config{}
+	err = yaml.UnmarshalStrict(fl, conf)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range conf.Secrets {
+		if v, ok := env.Lookup(name); ok {
+			conf.secretValues = append(conf.secretValues, v)
+		}
+	}
+	conf.resolvePaths(filepath.Dir(path))
+	conf.files = files
+	return conf, nil
+}
+
+// SecretValues returns values of variables of secrets, they are masked in log output and records
+func (c *Config) SecretValues() []string {
+	return c.secretValues
+}
+
+// Files returns files the config is read from: the config, its extends and include files,
+// files of cases and replays of mocks
+func (c *Config) Files() []string {
+	out := append([]string(nil), c.files...)
+	for _, mc := range c.AllMocks() {
+		for _, h := range mc {
+			if h.Replay != "" && !containsString(out, h.Replay) {
+				out = append(out, h.Replay)
+			}
+		}
+	}
+	return out
+}
+
+// resolvePaths makes file paths in config relative to the config directory
+func (c *Config) resolvePaths(dir string) {
+	resolvePath(&c.OpenAPI, dir)
+	resolvePath(&c.Proto, dir)
+	c.Mocks.resolvePaths(dir)
+	for name, pm := range c.ProtocolMocks {
+		resolvePath(&pm.Proto, dir)
+		c.ProtocolMocks[name] = pm
+	}
+	c.PingRequest.Expect.resolvePaths(dir)
+	resolveStepsPaths(c.BeforeEach, dir)
+	resolveStepsPaths(c.AfterEach, dir)
+	for i := range c.Tests {
+		t := &c.Tests[i]
+		t.Request.Expect.resolvePaths(dir)
+		t.Expect.ExpectConfig.resolvePaths(dir)
+		for _, e := range t.Expect.Mocks {
+			e.resolvePaths(dir)
+		}
+		for i := range t.Expect.Messages {
+			t.Expect.Messages[i].resolvePaths(dir)
+		}
+		resolveStepsPaths(t.Steps, dir)
+		t.Mocks.resolvePaths(dir)
+	}
+}
+
+func resolveStepsPaths(steps []StepConfig, dir string) {
+	for i := range steps {
+		s := &steps[i]
+		if s.Request != nil {
+			s.Request.Expect.resolvePaths(dir)
+		}
+		s.Expect.ExpectConfig.resolvePaths(dir)
+		for _, e := range s.Expect.Mocks {
+			e.resolvePaths(dir)
+		}
+		for j := range s.Expect.Messages {
+			s.Expect.Messages[j].resolvePaths(dir)
+		}
+		if s.Wait != nil {
+			s.Wait.Request.Expect.resolvePaths(dir)
+			s.Wait.Expect.resolvePaths(dir)
+		}
+	}
+}
+
+// AllMocks returns mocks of config and of all tests
+func (c *Config) AllMocks() []MockConfigs {
+	out := []MockConfigs{c.Mocks}
+	for _, t := range c.Tests {
+		out = append(out, t.Mocks)
+	}
+	return out
+}
+
+func (mc MockConfigs) resolvePaths(dir string) {
+	for name, h := range mc {
+		resolvePath(&h.OpenAPI, dir)
+		if h.Replay == "" && h.Upstream != "" {
+			h.Replay = name + ".recorded.yaml"
+		}
+		resolvePath(&h.Replay, dir)
+		mc[name] = h
+	}
+}
+
+// LoadReplays appends recorded stubs to stubs of mock hosts,
+// missing replay files are created by the record mode,
+// stubs of a previous load are replaced, so the config may be loaded again
+func (c *Config) LoadReplays(record bool) error {
+	for _, mc := range c.AllMocks() {
+		err := mc.loadReplays(record)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadReplays appends recorded stubs to stubs of hosts instead of previously loaded ones
+func (mc MockConfigs) loadReplays(record bool) error {
+	for name, h := range mc {
+		if h.Replay == "" {
+			continue
+		}
+		n := len(h.Stubs) - h.replayed
+		h.Stubs, h.replayed = h.Stubs[:n:n], 0
+		mc[name] = h
+
+		fl, err := ioutil.ReadFile(h.Replay)
+		if err != nil {
+			if os.IsNotExist(err) && record {
+				continue
+			}
+			return fmt.Errorf("read replay of %q mock (run `microtest record` to create it): %v", name, err)
+		}
+
+		var stubs []MockConfig
+		err = yaml.Unmarshal(fl, &stubs)
+		if err != nil {
+			return fmt.Errorf("parse replay of %q mock (%s): %v", name, h.Replay, err)
+		}
+		h.Stubs, h.replayed = append(h.Stubs, stubs...), len(stubs)
+		mc[name] = h
+	}
+	return nil
+}
+
+func resolvePath(p *string, dir string) {
+	if *p != "" && !filepath.IsAbs(*p) {
+		*p = filepath.Join(dir, *p)
+	}
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestRead(t *testing.T) {
+	type args struct {
+		path string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *Config
+		wantErr bool
+	}{
+		// TODO: Add test cases.
+		{"file not found", args{"not_found"}, nil, true},
+		{"file not found", args{"testdata/wrong_config.yaml"}, nil, true},
+		{"file not found", args{"testdata/correct_config.yaml"}, &Config{Name: "correct config", files: []string{"testdata/correct_config.yaml"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Read(tt.args.path, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Read() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Read() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaConfig_UnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want SchemaConfig
+	}{
+		{"path", `schema: ./user.json`, SchemaConfig{Path: "./user.json"}},
+		{"json", `schema: '{"type": "object"}'`, SchemaConfig{Inline: []byte(`{"type": "object"}`)}},
+		{"yaml", "schema:\n  type: object", SchemaConfig{Inline: []byte(`{"type":"object"}`)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got ExpectConfig
+			if err := yaml.Unmarshal([]byte(tt.yaml), &got); err != nil {
+				t.Fatalf("yaml.Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Schema, &tt.want) {
+				t.Errorf("SchemaConfig = %+v, want %+v", got.Schema, tt.want)
+			}
+		})
+	}
+}
+
+func TestMockFallbackConfig_UnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    MockFallbackConfig
+		wantErr bool
+	}{
+		{"mode", `strict`, MockFallbackConfig{Mode: FallbackStrict}, false},
+		{"url", `{url: http://users}`, MockFallbackConfig{Mode: FallbackProxy, URL: "http://users"}, false},
+		{"body", `{body: "{}"}`, MockFallbackConfig{Mode: FallbackDefault, Body: "{}"}, false},
+		{"proxy without url", `proxy`, MockFallbackConfig{}, true},
+		{"proxy mode without url", `{mode: proxy}`, MockFallbackConfig{}, true},
+		{"unknown mode", `unknown`, MockFallbackConfig{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got MockFallbackConfig
+			err := yaml.Unmarshal([]byte(tt.yaml), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("yaml.Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MockFallbackConfig = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStepConfig_Kind(t *testing.T) {
+	var steps []StepConfig
+	err := yaml.Unmarshal([]byte(`
+- request: {method: POST, url: /users}
+  expect: {status: 201}
+- wait:
+    request: {url: /users/1}
+    timeout: 5s
+- exec: {service: postgres, command: psql -c 'select 1'}
+- reset_mocks: true
+- sleep: 100ms
+- publish: [{broker: events, topic: users}]
+`), &steps)
+	if err != nil {
+		t.Fatalf("yaml.Unmarshal() error = %v", err)
+	}
+
+	want := []string{"POST /users", "wait /users/1", "exec psql -c 'select 1'", "reset mocks", "sleep 100ms", "publish"}
+	if len(steps) != len(want) {
+		t.Fatalf("len(steps) = %d, want %d", len(steps), len(want))
+	}
+	for i, s := range steps {
+		if got := s.Kind(); got != want[i] {
+			t.Errorf("steps[%d].Kind() = %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestConfig_LoadReplays(t *testing.T) {
+	dir := t.TempDir()
+	replay := filepath.Join(dir, "users.recorded.yaml")
+	if err := ioutil.WriteFile(replay, []byte("- {method: GET, url: /users/1, body: '{}'}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf := &Config{
+		Mocks: MockConfigs{"users": {Stubs: []MockConfig{{URL: "/users"}}, Replay: replay}},
+		Tests: []TestConfig{{Mocks: MockConfigs{"users": {Replay: replay}}}},
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := conf.LoadReplays(false); err != nil {
+			t.Fatalf("Config.LoadReplays() error = %v", err)
+		}
+	}
+	if got := len(conf.Mocks["users"].Stubs); got != 2 {
+		t.Errorf("stubs = %d, want 2", got)
+	}
+	if got := len(conf.Tests[0].Mocks["users"].Stubs); got != 1 {
+		t.Errorf("stubs of test = %d, want 1", got)
+	}
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Find returns test files of the path: a file, a directory (recursively)
+// or a glob ("suites/**/*.yaml"), files and directories with "_" or "." prefix are skipped
+func Find(p string) ([]string, error) {
+	base, pattern := SplitGlob(p)
+
+	info, err := os.Stat(base)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{p}, nil
+	}
+
+	var tests []string
+	err = filepath.Walk(base, func(fp string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := fi.Name()
+		if fp != base && (strings.HasPrefix(name, "_") || strings.HasPrefix(name, ".")) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fi.IsDir() || !isTestFile(name) {
+			return nil
+		}
+
+		if pattern != "" {
+			rel, err := filepath.Rel(base, fp)
+			if err != nil {
+				return err
+			}
+			ok, err := matchSegments(strings.Split(pattern, "/"), strings.Split(filepath.ToSlash(rel), "/"), map[string]string{})
+			if err != nil || !ok {
+				return err
+			}
+		}
+		tests = append(tests, fp)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(tests)
+	return tests, nil
+}
+
+// SplitGlob splits the path to a directory without glob symbols and a pattern of files in it
+func SplitGlob(p string) (base, pattern string) {
+	segs := strings.Split(filepath.ToSlash(p), "/")
+	for i, seg := range segs {
+		if strings.ContainsAny(seg, "*?[") {
+			base = strings.Join(segs[:i], "/")
+			if base == "" && i > 0 {
+				// the root of an absolute path
+				base = "/"
+			} else if base == "" {
+				base = "."
+			}
+			return base, strings.Join(segs[i:], "/")
+		}
+	}
+	return p, ""
+}
+
+func isTestFile(name string) bool {
+	if strings.HasSuffix(name, ".recorded.yaml") {
+		return false
+	}
+	return strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml")
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindTests(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{
+		"01-base.yaml",
+		"_base.yaml",
+		"billing.recorded.yaml",
+		"readme.md",
+		"suites/users/create.yaml",
+		"suites/users/delete.yml",
+		"suites/orders/create.yaml",
+		"_shared/mocks.yaml",
+	} {
+		p := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want []string
+	}{
+		{"directory", dir, []string{"01-base.yaml", "suites/orders/create.yaml", "suites/users/create.yaml", "suites/users/delete.yml"}},
+		{"glob", filepath.Join(dir, "suites/**/create.yaml"), []string{"suites/orders/create.yaml", "suites/users/create.yaml"}},
+		{"glob of directory", filepath.Join(dir, "suites/users/*"), []string{"suites/users/create.yaml", "suites/users/delete.yml"}},
+		{"file", filepath.Join(dir, "_base.yaml"), []string{"_base.yaml"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Find(tt.path)
+			if err != nil {
+				t.Fatalf("Find() error = %v", err)
+			}
+			for i := range got {
+				got[i], _ = filepath.Rel(dir, got[i])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitGlob(t *testing.T) {
+	tests := []struct {
+		path        string
+		wantBase    string
+		wantPattern string
+	}{
+		{"suites/**/*.yaml", "suites", "**/*.yaml"},
+		{"*.yaml", ".", "*.yaml"},
+		{"/*.yaml", "/", "*.yaml"},
+		{"/tests/*.yaml", "/tests", "*.yaml"},
+		{"tests/users.yaml", "tests/users.yaml", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			base, pattern := SplitGlob(tt.path)
+			if base != tt.wantBase || pattern != tt.wantPattern {
+				t.Errorf("SplitGlob() = %q, %q, want %q, %q", base, pattern, tt.wantBase, tt.wantPattern)
+			}
+		})
+	}
+}
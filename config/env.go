@@ -0,0 +1,311 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Env looks up variables in --set values, then in the environment, then in --env-file,
+// a nil Env looks up the environment only
+type Env struct {
+	set  map[string]string
+	file map[string]string
+}
+
+func (e *Env) Lookup(name string) (string, bool) {
+	if e == nil {
+		return os.LookupEnv(name)
+	}
+	if v, ok := e.set[name]; ok {
+		return v, true
+	}
+	if v, ok := os.LookupEnv(name); ok {
+		return v, true
+	}
+	v, ok := e.file[name]
+	return v, ok
+}
+
+// NewEnv reads variables of the env file (if any) and sets "key=value" values
+func NewEnv(envFile string, set []string) (*Env, error) {
+	e := &Env{}
+	if envFile != "" {
+		vs, err := readEnvFile(envFile)
+		if err != nil {
+			return nil, fmt.Errorf("read env file: %v", err)
+		}
+		e.file = vs
+	}
+	for _, kv := range set {
+		err := e.Set(kv)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Set sets a variable by "key=value"
+func (e *Env) Set(kv string) error {
+	i := strings.Index(kv, "=")
+	if i <= 0 {
+		return fmt.Errorf("wrong --set %q: expected key=value", kv)
+	}
+	if e.set == nil {
+		e.set = map[string]string{}
+	}
+	e.set[kv[:i]] = kv[i+1:]
+	return nil
+}
+
+// FileVars are variables of the env file
+func (e *Env) FileVars() map[string]string {
+	if e == nil {
+		return nil
+	}
+	return e.file
+}
+
+// readEnvFile reads KEY=VALUE lines, empty lines and # comments are skipped
+func readEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	out := map[string]string{}
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		i := strings.Index(line, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, n)
+		}
+		val := strings.TrimSpace(line[i+1:])
+		if len(val) >= 2 && (val[0] == '"' || val[0] == '\'') && val[len(val)-1] == val[0] {
+			val = val[1 : len(val)-1]
+		}
+		out[strings.TrimSpace(line[:i])] = val
+	}
+	return out, sc.Err()
+}
+
+// expandEnv replaces ${VAR} and ${VAR:-default} (the default is used for unset and empty variables),
+// $${ is an escaped ${
+func expandEnv(s string, lookup func(string) (string, bool)) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var out strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			out.WriteString(s)
+			return out.String(), nil
+		}
+		if i > 0 && s[i-1] == '$' {
+			out.WriteString(s[:i])
+			out.WriteString("{")
+			s = s[i+2:]
+			continue
+		}
+		out.WriteString(s[:i])
+
+		end := strings.Index(s[i:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("unclosed variable: %s", s[i:])
+		}
+		name, def := s[i+2:i+end], ""
+		hasDef := false
+		if j := strings.Index(name, ":-"); j >= 0 {
+			name, def, hasDef = name[:j], name[j+2:], true
+		}
+		if name == "" {
+			return "", fmt.Errorf("empty variable name: %s", s[i:i+end+1])
+		}
+
+		val, ok := lookup(name)
+		switch {
+		case hasDef && val == "":
+			val = def
+		case !ok:
+			return "", fmt.Errorf("variable is not set: %s", name)
+		}
+		out.WriteString(val)
+		s = s[i+end+1:]
+	}
+}
+
+// expandNode expands variables in scalar values of the tree,
+// an expanded plain scalar gets its type by the new value (port: ${PORT} is an int)
+func expandNode(n *yamlv3.Node, lookup func(string) (string, bool), onErr func(*yamlv3.Node, error)) bool {
+	changed := false
+	switch n.Kind {
+	case yamlv3.ScalarNode:
+		val, err := expandEnv(n.Value, lookup)
+		if err != nil {
+			onErr(n, err)
+			return false
+		}
+		if val != n.Value {
+			n.Value = val
+			if n.Style == 0 {
+				n.Tag = ""
+			}
+			changed = true
+		}
+	case yamlv3.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			if expandNode(n.Content[i], lookup, onErr) {
+				changed = true
+			}
+		}
+	default:
+		for _, c := range n.Content {
+			if expandNode(c, lookup, onErr) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// EnvNames returns names of variables of ${VAR} and of secrets in the config and its base files
+func EnvNames(path string) ([]string, error) {
+	fl, _, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc yamlv3.Node
+	err = yamlv3.Unmarshal(fl, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	expandNode(&doc, func(name string) (string, bool) {
+		seen[name] = true
+		return "", true
+	}, func(*yamlv3.Node, error) {})
+
+	var conf struct {
+		Secrets []string `yaml:"secrets"`
+	}
+	if len(doc.Content) > 0 && doc.Content[0].Kind == yamlv3.MappingNode {
+		// a wrong list of secrets is reported by read of config
+		_ = doc.Content[0].Decode(&conf)
+	}
+	for _, name := range conf.Secrets {
+		seen[name] = true
+	}
+
+	out := make([]string, 0, len(seen))
+	for name := range seen {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// expandConfig expands variables and cases of tests of the config,
+// the config is returned as is without them
+func expandConfig(fl []byte, dir string, env *Env) ([]byte, error) {
+	var doc yamlv3.Node
+	if yamlv3.Unmarshal(fl, &doc) != nil {
+		// errors are reported by unmarshal of config
+		return fl, nil
+	}
+
+	var errs []string
+	onErr := func(n *yamlv3.Node, err error) {
+		errs = append(errs, fmt.Sprintf("line %d: %v", n.Line, err))
+	}
+	changed := expandNode(&doc, env.Lookup, onErr)
+	if len(errs) == 0 && len(doc.Content) > 0 && expandCases(doc.Content[0], dir, onErr) {
+		changed = true
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("expand config: %s", strings.Join(errs, "; "))
+	}
+	if !changed {
+		return fl, nil
+	}
+	return yamlv3.Marshal(&doc)
+}
+
+// SecretMask replaces secret values by ***
+type SecretMask struct {
+	mx       sync.RWMutex
+	values   []string
+	replacer *strings.Replacer
+}
+
+func (s *SecretMask) Add(values ...string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	for _, v := range values {
+		if v == "" || containsString(s.values, v) {
+			continue
+		}
+		s.values = append(s.values, v)
+	}
+	// longer values first, so a secret containing another one is masked whole
+	sort.Slice(s.values, func(i, j int) bool { return len(s.values[i]) > len(s.values[j]) })
+
+	var oldnew []string
+	for _, v := range s.values {
+		oldnew = append(oldnew, v, "***")
+	}
+	s.replacer = strings.NewReplacer(oldnew...)
+}
+
+func (s *SecretMask) Mask(str string) string {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	if s.replacer == nil {
+		return str
+	}
+	return s.replacer.Replace(str)
+}
+
+// Writer masks secrets written to w, log writes are whole lines so secrets are not split
+func (s *SecretMask) Writer(w io.Writer) io.Writer {
+	return &maskWriter{w: w, mask: s}
+}
+
+type maskWriter struct {
+	w    io.Writer
+	mask *SecretMask
+}
+
+func (m *maskWriter) Write(p []byte) (int, error) {
+	_, err := io.WriteString(m.w, m.mask.Mask(string(p)))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
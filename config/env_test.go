@@ -0,0 +1,95 @@
+package config
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	vs := map[string]string{"HOST": "users", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		v, ok := vs[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"http://${HOST}:8080", "http://users:8080", false},
+		{"${PORT:-8080}", "8080", false},
+		{"${EMPTY:-default}", "default", false},
+		{"${HOST:-default}", "users", false},
+		{"$.items[0]", "$.items[0]", false},
+		{"$${HOST} ${HOST}", "${HOST} users", false},
+		{"${UNKNOWN}", "", true},
+		{"${HOST", "", true},
+	}
+	for _, tt := range tests {
+		got, err := expandEnv(tt.in, lookup)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("expandEnv(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("expandEnv(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRead_Env(t *testing.T) {
+	env, err := NewEnv("testdata/env/test.env", []string{"MT_USER_ID=42", "MT_IMAGE=users:v2"})
+	if err != nil {
+		t.Fatalf("NewEnv() error = %v", err)
+	}
+
+	conf, err := Read("testdata/env/env.yaml", env)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if conf.Image != "users:v2" || conf.Port != 8080 {
+		t.Errorf("image, port = %q, %d", conf.Image, conf.Port)
+	}
+	r := conf.Tests[0].Request
+	if r.URL != "/users/42" || r.Headers["Authorization"] != "Bearer secret-token" {
+		t.Errorf("request = %q, %v", r.URL, r.Headers)
+	}
+	if conf.Tests[0].Expect.Body != `{"price": "${price}"}` {
+		t.Errorf("body = %q", conf.Tests[0].Expect.Body)
+	}
+
+	secrets := &SecretMask{}
+	secrets.Add(conf.SecretValues()...)
+	var out bytes.Buffer
+	_, _ = secrets.Writer(&out).Write([]byte("Authorization: Bearer secret-token\n"))
+	if out.String() != "Authorization: Bearer ***\n" {
+		t.Errorf("masked = %q", out.String())
+	}
+
+	problems := Validate("testdata/env/env.yaml", nil)
+	var msgs []string
+	for _, p := range problems {
+		msgs = append(msgs, p.Error())
+	}
+	want := []string{
+		"testdata/env/env.yaml:1:8: variable is not set: MT_IMAGE",
+		"testdata/env/env.yaml:9:12: variable is not set: MT_USER_ID",
+		"testdata/env/env.yaml:11:24: variable is not set: MT_TOKEN",
+	}
+	if !reflect.DeepEqual(msgs, want) {
+		t.Errorf("Validate() = %q, want %q", msgs, want)
+	}
+}
+
+func TestEnvNames(t *testing.T) {
+	got, err := EnvNames("testdata/env/env.yaml")
+	if err != nil {
+		t.Fatalf("EnvNames() error = %v", err)
+	}
+	want := []string{"MT_IMAGE", "MT_PORT", "MT_TOKEN", "MT_USER_ID"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EnvNames() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// pathKeys are keys of file paths in config, they are resolved relative to the file defining them
+var pathKeys = map[string]bool{
+	"cases":   true,
+	"openapi": true,
+	"proto":   true,
+	"replay":  true,
+	"schema":  true,
+}
+
+// readConfigFile reads the config with `extends` and `include` files merged into it:
+// mappings are merged recursively, other values of the config override ones of base files.
+// Files are the config and its base files
+func readConfigFile(path string) (fl []byte, files []string, err error) {
+	fl, err = ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	files = []string{path}
+
+	var tree map[interface{}]interface{}
+	if yaml.Unmarshal(fl, &tree) != nil || (tree["extends"] == nil && tree["include"] == nil) {
+		// errors are reported by unmarshal of config
+		return fl, files, nil
+	}
+
+	files = nil
+	merged, err := readConfigTree(path, map[string]bool{}, &files)
+	if err != nil {
+		return nil, nil, err
+	}
+	fl, err = yaml.Marshal(merged)
+	return fl, files, err
+}
+
+func readConfigTree(path string, visited map[string]bool, files *[]string) (map[interface{}]interface{}, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if visited[abs] {
+		return nil, fmt.Errorf("cyclic extends of config: %s", path)
+	}
+	visited[abs] = true
+	defer delete(visited, abs)
+
+	fl, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	*files = append(*files, path)
+
+	var tree map[interface{}]interface{}
+	err = yaml.Unmarshal(fl, &tree)
+	if err != nil {
+		return nil, fmt.Errorf("parse config (%s): %v", path, err)
+	}
+	if tree == nil {
+		tree = map[interface{}]interface{}{}
+	}
+
+	var bases []string
+	for _, key := range []string{"extends", "include"} {
+		files, err := stringList(tree[key])
+		if err != nil {
+			return nil, fmt.Errorf("%q of config (%s): %v", key, path, err)
+		}
+		bases = append(bases, files...)
+		delete(tree, key)
+	}
+
+	dir := filepath.Dir(path)
+	out := map[interface{}]interface{}{}
+	for _, base := range bases {
+		if !filepath.IsAbs(base) {
+			base = filepath.Join(dir, base)
+		}
+		baseTree, err := readConfigTree(base, visited, files)
+		if err != nil {
+			return nil, err
+		}
+		resolveTreePaths(baseTree, filepath.Dir(base))
+		out = mergeYAML(out, baseTree).(map[interface{}]interface{})
+	}
+	return mergeYAML(out, tree).(map[interface{}]interface{}), nil
+}
+
+func stringList(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expect a file path: %v", item)
+			}
+			out = append(out, s)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("expect a file path or a list of them: %v", v)
+}
+
+// mergeYAML merges mappings recursively, other values are replaced by override
+func mergeYAML(base, override interface{}) interface{} {
+	bm, ok := base.(map[interface{}]interface{})
+	om, ok2 := override.(map[interface{}]interface{})
+	if !ok || !ok2 {
+		return override
+	}
+
+	out := make(map[interface{}]interface{}, len(bm)+len(om))
+	for k, v := range bm {
+		out[k] = v
+	}
+	for k, v := range om {
+		if bv, ok := out[k]; ok {
+			out[k] = mergeYAML(bv, v)
+		} else {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// resolveTreePaths makes relative paths of a base file absolute,
+// so they don't depend on the directory of the extending config
+func resolveTreePaths(v interface{}, dir string) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		for k, val := range v {
+			if key, ok := k.(string); ok && pathKeys[key] {
+				if s, ok := val.(string); ok && s != "" && !strings.HasPrefix(strings.TrimSpace(s), "{") && !filepath.IsAbs(s) {
+					if abs, err := filepath.Abs(filepath.Join(dir, s)); err == nil {
+						v[k] = abs
+					}
+					continue
+				}
+			}
+			resolveTreePaths(val, dir)
+		}
+	case []interface{}:
+		for _, val := range v {
+			resolveTreePaths(val, dir)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRead_extends(t *testing.T) {
+	conf, err := Read("testdata/extends/suites/users.yaml", nil)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	openapi, _ := filepath.Abs("testdata/extends/openapi.yaml")
+	if conf.Name != "users" || conf.Image != "users:latest" || conf.Port != 9000 || conf.OpenAPI != openapi {
+		t.Errorf("Read() = name %q, image %q, port %d, openapi %q", conf.Name, conf.Image, conf.Port, conf.OpenAPI)
+	}
+	if conf.PingRequest.URL != "/health" || conf.PingRequest.Count != 5 {
+		t.Errorf("Read() ping_request = %+v", conf.PingRequest)
+	}
+	if len(conf.Mocks["billing"].Stubs) != 1 || len(conf.Services) != 1 {
+		t.Errorf("Read() mocks = %v, services = %v", conf.Mocks, conf.Services)
+	}
+	if p := conf.Tests[0].Expect.Schema.Path; p != "testdata/extends/schemas/user.json" {
+		t.Errorf("Read() schema = %q", p)
+	}
+}
+
+func TestMergeYAML(t *testing.T) {
+	base := map[interface{}]interface{}{
+		"image": "a",
+		"mocks": map[interface{}]interface{}{"billing": []interface{}{1}, "users": []interface{}{2}},
+	}
+	override := map[interface{}]interface{}{
+		"image": "b",
+		"mocks": map[interface{}]interface{}{"billing": []interface{}{3}},
+	}
+	got := mergeYAML(base, override).(map[interface{}]interface{})
+	mocks := got["mocks"].(map[interface{}]interface{})
+	if got["image"] != "b" || mocks["billing"].([]interface{})[0] != 3 || mocks["users"].([]interface{})[0] != 2 {
+		t.Errorf("mergeYAML() = %v", got)
+	}
+}
+
+func TestConfig_Files(t *testing.T) {
+	conf, err := Read("testdata/cases/suites/extends.yaml", nil)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	csv, _ := filepath.Abs("testdata/cases/users.csv")
+	want := []string{"testdata/cases/suites/extends.yaml", "testdata/cases/_base.yaml", csv}
+	if got := conf.Files(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Config.Files() = %v, want %v", got, want)
+	}
+}
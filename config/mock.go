@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+func (m *MockConfig) Equal(method, u string, body []byte) error {
+	errs := m.Mismatches(method, u, body)
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// Mismatches returns all reasons why the request doesn't match the stub
+func (m *MockConfig) Mismatches(method, u string, body []byte) []error {
+	_, errs := m.Match(method, u, body)
+	return errs
+}
+
+// Match checks the request and returns params captured from the url path
+func (m *MockConfig) Match(method, u string, body []byte) (map[string]string, []error) {
+	if m == nil {
+		return nil, nil
+	}
+	var errs []error
+	params, err := m.MatchURL(u)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if m.Method != "" {
+		if m.Method != method {
+			errs = append(errs, fmt.Errorf("Wrong method: %s (expect: %s)", method, m.Method))
+		}
+	}
+
+	return params, errs
+}
+
+// EqualURL checks the url of the request
+func (m *MockConfig) EqualURL(u string) error {
+	_, err := m.MatchURL(u)
+	return err
+}
+
+// MatchURL checks the url of the request and returns params captured from its path
+func (m *MockConfig) MatchURL(u string) (map[string]string, error) {
+	if m.URL == "" {
+		return nil, nil
+	}
+
+	inputURL, err := url.Parse(u)
+	if err != nil {
+		return nil, fmt.Errorf("Error on parse input url (%s): %v", u, err)
+	}
+
+	if strings.HasPrefix(m.URL, RegexpPrefix) {
+		params, ok, err := matchPath(m.URL, inputURL.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("Wrong url: %s (expect: %s)", inputURL.Path, m.URL)
+		}
+		return params, nil
+	}
+
+	mockURL, err := url.Parse(m.URL)
+	if err != nil {
+		return nil, fmt.Errorf("Error on parse config url (%s): %v", m.URL, err)
+	}
+
+	params, ok, err := matchPath(mockURL.Path, inputURL.Path)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("Wrong url: %s (expect: %s)", inputURL.Path, mockURL.Path)
+	}
+
+	for k, vs := range mockURL.Query() {
+		if inputVs, ok := inputURL.Query()[k]; ok {
+			if len(vs) > len(inputVs) {
+				return nil, fmt.Errorf("Wrong query %q len: %s (expect: %s)", k, strings.Join(inputVs, ","), strings.Join(vs, ","))
+			}
+			for i, q := range vs {
+				if q != inputVs[i] {
+					return nil, fmt.Errorf("Wrong query %q on position %d: %s (expect: %s)", k, i, strings.Join(inputVs, ","), strings.Join(vs, ","))
+				}
+			}
+		} else {
+			return nil, fmt.Errorf("Not found query key: %s", k)
+		}
+	}
+	return params, nil
+}
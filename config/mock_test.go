@@ -0,0 +1,66 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMockConfig_EqualURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		configURL string
+		expectURL string
+		wantErr   bool
+	}{
+		// TODO: Add test cases.
+		{"base", "/hello", "/hello", false},
+		{"base query", "/hello?name=mike&age=12,14", "/hello?age=12,14&name=mike", false},
+
+		{"false query", "/hello?name=mike&age=11", "/hello?age=12&name=mike", true},
+
+		{"template", "/users/{id}/orders", "/users/12/orders", false},
+		{"template with query", "/users/{id}?full=1", "/users/12?full=1", false},
+		{"false template", "/users/{id}/orders", "/users/12/items", true},
+		{"glob", "/files/*.json", "/files/a.json", false},
+		{"false glob", "/files/*.json", "/files/a/b.json", true},
+		{"double star", "/static/**/app.js", "/static/v1/js/app.js", false},
+		{"double star tail", "/static/**", "/static/v1/js/app.js", false},
+		{"regexp", `~^/users/\d+$`, "/users/12", false},
+		{"false regexp", `~^/users/\d+$`, "/users/mike", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &MockConfig{
+				URL: tt.configURL,
+				Out: `{"result": "ok"}`,
+			}
+			if err := m.EqualURL(tt.expectURL); (err != nil) != tt.wantErr {
+				t.Errorf("MockConfig.EqualURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMockConfig_MatchURLParams(t *testing.T) {
+	tests := []struct {
+		name      string
+		configURL string
+		inputURL  string
+		want      map[string]string
+	}{
+		{"template", "/users/{id}/orders/{order}", "/users/12/orders/7", map[string]string{"id": "12", "order": "7"}},
+		{"regexp", `~^/users/(?P<id>\d+)$`, "/users/12", map[string]string{"id": "12"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &MockConfig{URL: tt.configURL}
+			got, err := m.MatchURL(tt.inputURL)
+			if err != nil {
+				t.Fatalf("MockConfig.MatchURL() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MockConfig.MatchURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
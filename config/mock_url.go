@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// RegexpPrefix marks a mock url (and an expected line of tcp script) as a regular expression, named groups are path params
+const RegexpPrefix = "~"
+
+var (
+	urlRegexps   = map[string]*regexp.Regexp{}
+	mxURLRegexps sync.Mutex
+)
+
+// matchPath matches the path by a pattern: an exact path, a route template ("/users/{id}"),
+// a glob ("/files/*.json", "/static/**") or a regular expression ("~^/users/(?P<id>\d+)$")
+func matchPath(pattern, p string) (map[string]string, bool, error) {
+	if strings.HasPrefix(pattern, RegexpPrefix) {
+		return matchPathRegexp(pattern[len(RegexpPrefix):], p)
+	}
+
+	params := map[string]string{}
+	ok, err := matchSegments(strings.Split(pattern, "/"), strings.Split(p, "/"), params)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return params, true, nil
+}
+
+func matchPathRegexp(expr, p string) (map[string]string, bool, error) {
+	mxURLRegexps.Lock()
+	re, ok := urlRegexps[expr]
+	if !ok {
+		var err error
+		re, err = regexp.Compile(expr)
+		if err != nil {
+			mxURLRegexps.Unlock()
+			return nil, false, fmt.Errorf("Error on compile url regexp (%s): %v", expr, err)
+		}
+		urlRegexps[expr] = re
+	}
+	mxURLRegexps.Unlock()
+
+	match := re.FindStringSubmatch(p)
+	if match == nil {
+		return nil, false, nil
+	}
+	params := map[string]string{}
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			params[name] = match[i]
+		}
+	}
+	return params, true, nil
+}
+
+func matchSegments(pattern, segs []string, params map[string]string) (bool, error) {
+	for len(pattern) > 0 {
+		pat := pattern[0]
+
+		if pat == "**" {
+			if len(pattern) == 1 {
+				return true, nil
+			}
+			for i := 0; i <= len(segs); i++ {
+				ok, err := matchSegments(pattern[1:], segs[i:], params)
+				if err != nil || ok {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+
+		if len(segs) == 0 {
+			return false, nil
+		}
+		seg := segs[0]
+
+		switch {
+		case strings.HasPrefix(pat, "{") && strings.HasSuffix(pat, "}"):
+			if seg == "" {
+				return false, nil
+			}
+			params[pat[1:len(pat)-1]] = seg
+		case strings.ContainsAny(pat, "*?["):
+			ok, err := path.Match(pat, seg)
+			if err != nil {
+				return false, fmt.Errorf("Error on match url glob (%s): %v", pat, err)
+			}
+			if !ok {
+				return false, nil
+			}
+		default:
+			if pat != seg {
+				return false, nil
+			}
+		}
+
+		pattern, segs = pattern[1:], segs[1:]
+	}
+	return len(segs) == 0, nil
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/vkd/microtest/duration"
+)
+
+// expandPattern matches ${VAR} and {{.case.<field>}}, they are expanded by Read
+const expandPattern = `\$\{|\{\{\s*\.case\.`
+
+var (
+	// durationSchema allows values of time.ParseDuration: "0", "-1.5h", "1m30s"
+	durationSchema = map[string]interface{}{"anyOf": []interface{}{
+		map[string]interface{}{
+			"type":    "string",
+			"pattern": `^[-+]?(0|(([0-9]+(\.[0-9]*)?|\.[0-9]+)(ns|us|µs|μs|ms|s|m|h))+)$`,
+		},
+		map[string]interface{}{"const": 0},
+		map[string]interface{}{"type": "string", "pattern": expandPattern},
+	}}
+	fileListSchema = map[string]interface{}{
+		"oneOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		},
+	}
+)
+
+// Schema returns JSON Schema of the test config generated from its types
+func Schema() ([]byte, error) {
+	g := &schemaGenerator{defs: map[string]interface{}{}}
+
+	root := g.structSchema(reflect.TypeOf(Config{}))
+	props := root["properties"].(map[string]interface{})
+	props["extends"] = fileListSchema
+	props["include"] = fileListSchema
+
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["title"] = "microtest config"
+	root["$defs"] = g.defs
+	return json.MarshalIndent(root, "", "  ")
+}
+
+type schemaGenerator struct {
+	defs map[string]interface{}
+}
+
+// typeSchema returns a schema of the type, structs are referenced from $defs
+func (g *schemaGenerator) typeSchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case reflect.TypeOf(duration.StringDuration(0)):
+		return durationSchema
+	case reflect.TypeOf(CasesConfig{}):
+		return map[string]interface{}{
+			"description": "rows of the table test or a path to a .csv/.json file",
+			"oneOf": []interface{}{
+				map[string]interface{}{"type": "string"},
+				map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "object"}},
+			},
+		}
+	case reflect.TypeOf(SchemaConfig{}):
+		return map[string]interface{}{
+			"description": "inline JSON Schema or a path to the schema file",
+			"type":        []interface{}{"string", "object", "boolean"},
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		// types of different packages may have the same name
+		pkg := strings.TrimPrefix(t.PkgPath(), path.Dir(reflect.TypeOf(Config{}).PkgPath())+"/")
+		name := strings.ReplaceAll(pkg, "/", ".") + "." + t.Name()
+		if _, ok := g.defs[name]; !ok {
+			g.defs[name] = true // recursive types
+			g.defs[name] = g.definition(t)
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + name}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": g.typeSchema(t.Elem()),
+		}
+	case reflect.Slice:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": g.typeSchema(t.Elem()),
+		}
+	case reflect.Bool:
+		return expandableSchema("boolean")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return expandableSchema("integer")
+	case reflect.Float32, reflect.Float64:
+		return expandableSchema("number")
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	}
+	return map[string]interface{}{}
+}
+
+// definition returns a schema of the struct including short forms of custom unmarshalers
+func (g *schemaGenerator) definition(t reflect.Type) map[string]interface{} {
+	s := g.structSchema(t)
+
+	switch t {
+	case reflect.TypeOf(MockHostConfig{}):
+		return map[string]interface{}{"oneOf": []interface{}{
+			map[string]interface{}{"type": "array", "items": g.typeSchema(reflect.TypeOf(MockConfig{}))},
+			s,
+		}}
+	case reflect.TypeOf(MockFallbackConfig{}):
+		modes := []interface{}{FallbackStrict, FallbackProxy, FallbackDefault}
+		s["properties"].(map[string]interface{})["mode"] = map[string]interface{}{"enum": modes}
+		// proxy requires url, so it has no short form
+		return map[string]interface{}{"oneOf": []interface{}{
+			map[string]interface{}{"enum": []interface{}{FallbackStrict, FallbackDefault}},
+			s,
+		}}
+	}
+	return s
+}
+
+// expandableSchema allows ${VAR} and {{.case.<field>}} instead of the value, they are expanded by Read
+func expandableSchema(typ string) map[string]interface{} {
+	return map[string]interface{}{"anyOf": []interface{}{
+		map[string]interface{}{"type": typ},
+		map[string]interface{}{"type": "string", "pattern": expandPattern},
+	}}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	for name, f := range yamlFields(t) {
+		props[name] = g.typeSchema(f.Type)
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+}
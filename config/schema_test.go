@@ -0,0 +1,107 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/vkd/microtest/schema"
+)
+
+func TestConfigSchema(t *testing.T) {
+	bs, err := Schema()
+	if err != nil {
+		t.Fatalf("Schema() error = %v", err)
+	}
+	sch, err := schema.Compile(bs)
+	if err != nil {
+		t.Fatalf("schema.Compile() error = %v", err)
+	}
+
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{"testdata/correct_config.yaml", false},
+		{"testdata/extends/_base.yaml", false},
+		{"testdata/extends/suites/users.yaml", false},
+		{"testdata/invalid_values.yaml", false},
+		{"testdata/cases/cases.yaml", false},
+		{"testdata/unknown_field.yaml", true},
+		{"testdata/invalid_config.yaml", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			fl, err := ioutil.ReadFile(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var v interface{}
+			if err = yaml.Unmarshal(fl, &v); err != nil {
+				t.Fatal(err)
+			}
+			body, err := json.Marshal(jsonCompatible(v))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = sch.Validate(body)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigSchema_Duration(t *testing.T) {
+	bs, err := Schema()
+	if err != nil {
+		t.Fatalf("Schema() error = %v", err)
+	}
+	sch, err := schema.Compile(bs)
+	if err != nil {
+		t.Fatalf("schema.Compile() error = %v", err)
+	}
+
+	tests := []struct {
+		sleep   string
+		wantErr bool
+	}{
+		{`"1m30s"`, false},
+		{`"0"`, false},
+		{`0`, false},
+		{`".5s"`, false},
+		{`"-1.5h"`, false},
+		{`"${SLEEP}"`, false},
+		{`"5 seconds"`, true},
+		{`"10"`, true},
+		{`"s"`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sleep, func(t *testing.T) {
+			err := sch.Validate([]byte(`{"tests": [{"name": "sleep", "steps": [{"sleep": ` + tt.sleep + `}]}]}`))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigSchema_Defs(t *testing.T) {
+	bs, err := Schema()
+	if err != nil {
+		t.Fatalf("Schema() error = %v", err)
+	}
+	var s struct {
+		Defs map[string]interface{} `json:"$defs"`
+	}
+	if err = json.Unmarshal(bs, &s); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"config.TestConfig", "retry.Config"} {
+		if _, ok := s.Defs[name]; !ok {
+			t.Errorf("$defs has no %q", name)
+		}
+	}
+}
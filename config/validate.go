@@ -0,0 +1,517 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	pathpkg "path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Error is a problem of config at the position of file
+type Error struct {
+	File   string
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Msg)
+}
+
+type yamlUnmarshaler interface {
+	UnmarshalYAML(func(interface{}) error) error
+}
+
+var (
+	yamlUnmarshalerType = reflect.TypeOf((*yamlUnmarshaler)(nil)).Elem()
+
+	// listFields are fields decoded from a sequence of the struct
+	listFields = map[reflect.Type]string{
+		reflect.TypeOf(MockHostConfig{}): "Stubs",
+	}
+	// opaqueTypes are decoded by themselves, their keys are not checked
+	opaqueTypes = map[reflect.Type]bool{
+		reflect.TypeOf(SchemaConfig{}): true,
+	}
+
+	reYAMLErrorLine = regexp.MustCompile(`^(yaml: )?(unmarshal errors:\s*)?line \d+: `)
+)
+
+// Validate checks fields and values of the config file and of its extends and include files
+// with variables of env, returns all problems at positions of the files defining them
+func Validate(path string, env *Env) []*Error {
+	conf, err := Read(path, env)
+	problems := validateFile(path, env, conf, map[string]bool{})
+	if err != nil && len(problems) == 0 {
+		problems = append(problems, &Error{File: path, Msg: err.Error()})
+	}
+	return problems
+}
+
+// validateFile checks the file and its base files by their own nodes.
+// Names of mocks, brokers and the image are checked by the merged config of the top file only
+func validateFile(path string, env *Env, merged *Config, visited map[string]bool) []*Error {
+	abs, err := filepath.Abs(path)
+	if err != nil || visited[abs] {
+		// cyclic extends are reported by read of config
+		return nil
+	}
+	visited[abs] = true
+
+	v := &configValidator{
+		file:      path,
+		positions: map[string]*yamlv3.Node{},
+		keys:      map[string]*yamlv3.Node{},
+	}
+
+	fl, err := ioutil.ReadFile(path)
+	if err != nil {
+		v.add("", "%v", err)
+		return v.problems
+	}
+
+	var doc yamlv3.Node
+	err = yamlv3.Unmarshal(fl, &doc)
+	if err != nil {
+		v.add("", "%s", strings.TrimPrefix(err.Error(), "yaml: "))
+		return v.problems
+	}
+	if len(doc.Content) == 0 {
+		v.add("", "config is empty")
+		return v.problems
+	}
+	root := doc.Content[0]
+	v.positions[""] = root
+
+	var problems []*Error
+	for _, base := range v.bases(root) {
+		if !filepath.IsAbs(base) {
+			base = filepath.Join(filepath.Dir(path), base)
+		}
+		problems = append(problems, validateFile(base, env, nil, visited)...)
+	}
+	return append(v.validateRoot(root, env, merged), problems...)
+}
+
+// bases returns paths of extends and include files of the config
+func (v *configValidator) bases(root *yamlv3.Node) []string {
+	var out []string
+	for _, key := range []string{"extends", "include"} {
+		n := mappingValue(root, key)
+		if n == nil {
+			continue
+		}
+		v.positions[key] = n
+		var files []string
+		if err := n.Decode(&files); err != nil {
+			var file string
+			if n.Decode(&file) != nil {
+				v.add(key, "expect a file path or a list of them")
+				continue
+			}
+			files = []string{file}
+		}
+		out = append(out, files...)
+	}
+	return out
+}
+
+func (v *configValidator) validateRoot(root *yamlv3.Node, env *Env, merged *Config) []*Error {
+	onErr := func(n *yamlv3.Node, err error) {
+		v.problems = append(v.problems, &Error{File: v.file, Line: n.Line, Column: n.Column, Msg: err.Error()})
+	}
+	expandNode(root, env.Lookup, onErr)
+	if len(v.problems) == 0 {
+		expandCases(root, filepath.Dir(v.file), onErr)
+	}
+	if len(v.problems) > 0 {
+		return v.sorted()
+	}
+	v.walk(root, reflect.TypeOf(Config{}), "")
+	if len(v.problems) > 0 {
+		return v.sorted()
+	}
+
+	// values of the file are checked without ones of its base files
+	var conf Config
+	fl, err := yamlv3.Marshal(root)
+	if err == nil {
+		err = yaml.Unmarshal(fl, &conf)
+	}
+	if err != nil {
+		v.add("", "%v", err)
+		return v.problems
+	}
+	v.validate(&conf, merged)
+	return v.sorted()
+}
+
+// configValidator keeps nodes of values and keys by paths like "tests[0].expect.body"
+type configValidator struct {
+	file      string
+	positions map[string]*yamlv3.Node
+	keys      map[string]*yamlv3.Node
+	problems  []*Error
+}
+
+// add reports the problem at the value of the path or of its nearest parent
+func (v *configValidator) add(path, format string, args ...interface{}) {
+	v.addAt(v.positions, path, format, args...)
+}
+
+// addKey reports the problem at the key of the path
+func (v *configValidator) addKey(path, format string, args ...interface{}) {
+	if _, ok := v.keys[path]; ok {
+		v.addAt(v.keys, path, format, args...)
+		return
+	}
+	v.add(path, format, args...)
+}
+
+func (v *configValidator) addAt(nodes map[string]*yamlv3.Node, path, format string, args ...interface{}) {
+	e := &Error{File: v.file, Msg: fmt.Sprintf(format, args...)}
+	for p := path; ; p = parentPath(p) {
+		if n, ok := nodes[p]; ok {
+			e.Line, e.Column = n.Line, n.Column
+			break
+		}
+		if p == "" {
+			break
+		}
+	}
+	v.problems = append(v.problems, e)
+}
+
+func (v *configValidator) sorted() []*Error {
+	sort.SliceStable(v.problems, func(i, j int) bool {
+		a, b := v.problems[i], v.problems[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	// copies of a test with cases have the same problems
+	seen := map[Error]bool{}
+	var out []*Error
+	for _, e := range v.problems {
+		if seen[*e] {
+			continue
+		}
+		seen[*e] = true
+		out = append(out, e)
+	}
+	return out
+}
+
+func parentPath(p string) string {
+	i := strings.LastIndexAny(p, ".[")
+	if i < 0 {
+		return ""
+	}
+	return p[:i]
+}
+
+func joinPath(p, key string) string {
+	if p == "" {
+		return key
+	}
+	return p + "." + key
+}
+
+// walk checks the node by the type: unknown fields, kinds of nodes and values of scalars
+func (v *configValidator) walk(n *yamlv3.Node, t reflect.Type, path string) {
+	if n.Kind == yamlv3.AliasNode {
+		n = n.Alias
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if n.Tag == "!!null" {
+		return
+	}
+
+	if reflect.PtrTo(t).Implements(yamlUnmarshalerType) {
+		err := n.Decode(reflect.New(t).Interface())
+		if opaqueTypes[t] || (err != nil && (t.Kind() != reflect.Struct || n.Kind == yamlv3.ScalarNode)) {
+			if err != nil {
+				v.add(path, "%s", reYAMLErrorLine.ReplaceAllString(err.Error(), ""))
+			}
+			return
+		}
+
+		// an error of the struct is reported at its field when it is found
+		count := len(v.problems)
+		if field, ok := listFields[t]; ok && n.Kind == yamlv3.SequenceNode {
+			f, _ := t.FieldByName(field)
+			v.walk(n, f.Type, path)
+		} else if n.Kind == yamlv3.MappingNode {
+			v.walkStruct(n, t, path)
+		}
+		if err != nil && len(v.problems) == count {
+			v.add(path, "%s", reYAMLErrorLine.ReplaceAllString(err.Error(), ""))
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if n.Kind != yamlv3.MappingNode {
+			v.add(path, "expect a mapping of %s", t.Name())
+			return
+		}
+		v.walkStruct(n, t, path)
+	case reflect.Map:
+		if n.Kind != yamlv3.MappingNode {
+			v.add(path, "expect a mapping")
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, val := n.Content[i], n.Content[i+1]
+			p := joinPath(path, key.Value)
+			v.keys[p], v.positions[p] = key, val
+			v.walk(val, t.Elem(), p)
+		}
+	case reflect.Slice:
+		if n.Kind != yamlv3.SequenceNode {
+			v.add(path, "expect a list")
+			return
+		}
+		for i, item := range n.Content {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			v.positions[p] = item
+			v.walk(item, t.Elem(), p)
+		}
+	default:
+		if n.Kind != yamlv3.ScalarNode {
+			v.add(path, "expect a value of %s", t.Kind())
+			return
+		}
+		err := n.Decode(reflect.New(t).Interface())
+		if err != nil {
+			v.add(path, "%s", reYAMLErrorLine.ReplaceAllString(err.Error(), ""))
+		}
+	}
+}
+
+func (v *configValidator) walkStruct(n *yamlv3.Node, t reflect.Type, path string) {
+	fields := yamlFields(t)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, val := n.Content[i], n.Content[i+1]
+		p := joinPath(path, key.Value)
+		v.keys[p], v.positions[p] = key, val
+
+		f, ok := fields[key.Value]
+		if !ok {
+			if path == "" && (key.Value == "extends" || key.Value == "include") {
+				continue
+			}
+			v.addKey(p, "unknown field %q of %s", key.Value, t.Name())
+			continue
+		}
+		v.walk(val, f.Type, p)
+	}
+}
+
+// yamlFields returns fields of the struct by yaml names including inline ones
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	out := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("yaml")
+		if tag == "-" || (f.PkgPath != "" && !f.Anonymous) {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if strings.Contains(tag, ",inline") {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			for k, sf := range yamlFields(ft) {
+				out[k] = sf
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		out[name] = f
+	}
+	return out
+}
+
+// validate checks values of the config: required fields, names of mocks, urls and json bodies.
+// Required fields and names of mocks and brokers are checked by the merged config if it is set
+func (v *configValidator) validate(conf, merged *Config) {
+	if merged != nil && merged.Image == "" {
+		v.add("", "'image' not found in config")
+	}
+
+	v.validateRequest("ping_request", &conf.PingRequest.RequestConfig)
+	v.validateMocks("mocks", conf.Mocks)
+	v.validateSteps("before_each", conf.BeforeEach, merged, nil)
+	v.validateSteps("after_each", conf.AfterEach, merged, nil)
+
+	for i := range conf.Tests {
+		t := &conf.Tests[i]
+		p := fmt.Sprintf("tests[%d]", i)
+
+		v.validateMocks(p+".mocks", t.Mocks)
+		v.validateSteps(p+".steps", t.Steps, merged, t)
+		if len(t.Steps) == 0 || t.Request.URL != "" {
+			v.validateRequest(p+".request", &t.Request)
+		}
+		v.validateExpectMocks(p+".expect", &t.Expect, merged, t)
+	}
+}
+
+func (v *configValidator) validateSteps(path string, steps []StepConfig, merged *Config, t *TestConfig) {
+	for i := range steps {
+		s := &steps[i]
+		p := fmt.Sprintf("%s[%d]", path, i)
+		if s.Request != nil {
+			v.validateRequest(p+".request", s.Request)
+		}
+		v.validateExpectMocks(p+".expect", &s.Expect, merged, t)
+		if s.Wait != nil {
+			v.validateRequest(p+".wait.request", &s.Wait.Request)
+			v.validateExpect(p+".wait.expect", &s.Wait.Expect)
+		}
+		if s.Exec != nil && s.Exec.Command == "" {
+			v.add(p+".exec", "'command' of exec not found")
+		}
+	}
+}
+
+func (v *configValidator) validateRequest(path string, r *RequestConfig) {
+	switch r.Protocol {
+	case "", ProtocolHTTP, ProtocolWebSocket, ProtocolSSE:
+		if _, err := url.Parse(r.URL); err != nil {
+			v.add(path+".url", "wrong url: %v", err)
+		}
+	case ProtocolGRPC:
+		if !strings.Contains(strings.TrimPrefix(r.URL, "/"), "/") {
+			v.add(path+".url", "wrong grpc method: %q (expect: pkg.Service/Method)", r.URL)
+		}
+	default:
+		v.add(path+".protocol", "unknown protocol: %q", r.Protocol)
+	}
+	v.validateJSON(path+".body", r.Body)
+	if r.Expect != nil {
+		v.validateExpect(path+".expect", r.Expect)
+	}
+}
+
+func (v *configValidator) validateExpectMocks(path string, e *ExpectMockConfig, merged *Config, t *TestConfig) {
+	v.validateExpect(path, &e.ExpectConfig)
+	for name, me := range e.Mocks {
+		p := joinPath(path+".mocks", name)
+		if merged != nil && !hasMock(name, merged, t) {
+			v.addKey(p, "unknown mock: %q", name)
+		}
+		v.validateExpect(p, &me)
+	}
+	for i := range e.Messages {
+		p := fmt.Sprintf("%s.messages[%d]", path, i)
+		if merged != nil {
+			if _, ok := merged.Brokers[e.Messages[i].Broker]; !ok {
+				v.add(p+".broker", "unknown broker: %q", e.Messages[i].Broker)
+			}
+		}
+		v.validateExpect(p, &e.Messages[i].ExpectConfig)
+	}
+}
+
+func hasMock(name string, conf *Config, t *TestConfig) bool {
+	if _, ok := conf.Mocks[name]; ok {
+		return true
+	}
+	if _, ok := conf.ProtocolMocks[name]; ok {
+		return true
+	}
+	if t != nil {
+		if _, ok := t.Mocks[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *configValidator) validateExpect(path string, e *ExpectConfig) {
+	if !e.IsRaw {
+		v.validateJSON(path+".body", e.Body)
+		v.validateJSON(path+".body_min", e.BodyMin)
+	}
+	if err := e.Comparator.Validate(); err != nil {
+		v.add(path+".arrays", "%v", err)
+	}
+	if e.Stream != nil {
+		for i := range e.Stream.Messages {
+			v.validateExpect(fmt.Sprintf("%s.stream.messages[%d]", path, i), &e.Stream.Messages[i].ExpectConfig)
+		}
+	}
+}
+
+func (v *configValidator) validateMocks(path string, mocks MockConfigs) {
+	for name, h := range mocks {
+		for i, stub := range h.Stubs {
+			p := fmt.Sprintf("%s.%s[%d]", path, name, i)
+			if _, ok := v.positions[p]; !ok {
+				p = fmt.Sprintf("%s.%s.stubs[%d]", path, name, i)
+			}
+			v.validateMockURL(p+".url", stub.URL)
+			if !strings.Contains(stub.Out, "{{") {
+				v.validateJSON(p+".body", stub.Out)
+			}
+		}
+	}
+}
+
+func (v *configValidator) validateMockURL(path, u string) {
+	switch {
+	case u == "":
+	case strings.HasPrefix(u, RegexpPrefix):
+		if _, err := regexp.Compile(u[len(RegexpPrefix):]); err != nil {
+			v.add(path, "wrong url regexp: %v", err)
+		}
+	case strings.HasPrefix(u, "/"):
+		mockURL, err := url.Parse(u)
+		if err != nil {
+			v.add(path, "wrong url: %v", err)
+			return
+		}
+		for _, seg := range strings.Split(mockURL.Path, "/") {
+			if _, err := pathpkg.Match(seg, ""); err != nil {
+				v.add(path, "wrong url glob %q: %v", seg, err)
+			}
+		}
+	default:
+		v.add(path, "wrong url: %q (expect: /path or ~regexp)", u)
+	}
+}
+
+// validateJSON checks bodies which look like json
+func (v *configValidator) validateJSON(path, body string) {
+	s := strings.TrimSpace(body)
+	if !strings.HasPrefix(s, "{") && !strings.HasPrefix(s, "[") {
+		return
+	}
+	var val interface{}
+	if err := json.Unmarshal([]byte(s), &val); err != nil {
+		v.add(path, "invalid json: %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	if problems := Validate("testdata/extends/suites/users.yaml", nil); len(problems) != 0 {
+		t.Errorf("Validate() of valid config = %v", problems)
+	}
+
+	var got []string
+	for _, e := range Validate("testdata/invalid_config.yaml", nil) {
+		got = append(got, e.Error())
+	}
+	want := []string{
+		`testdata/invalid_config.yaml:10:15: unknown mock fallback mode: "unknown"`,
+		`testdata/invalid_config.yaml:16:16: time: unknown unit " hour" in duration "1 hour"`,
+		`testdata/invalid_config.yaml:17:5: unknown field "expcet" of TestConfig`,
+		`testdata/invalid_config.yaml:23:7: unknown field "bodymin" of ExpectMockConfig`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Validate() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	var fixed []string
+	for _, e := range Validate("testdata/invalid_values.yaml", nil) {
+		fixed = append(fixed, e.Error())
+	}
+	want = []string{
+		`testdata/invalid_values.yaml:1:1: 'image' not found in config`,
+		`testdata/invalid_values.yaml:5:12: wrong url: "balance" (expect: /path or ~regexp)`,
+		`testdata/invalid_values.yaml:6:13: invalid json: unexpected end of JSON input`,
+		`testdata/invalid_values.yaml:9:14: wrong url regexp: error parsing regexp: missing closing ): ` + "`" + `^/users/(\d+$` + "`",
+		`testdata/invalid_values.yaml:14:12: wrong url: parse "/users/%zz": invalid URL escape "%zz"`,
+		`testdata/invalid_values.yaml:19:13: invalid json: invalid character '}' looking for beginning of object key string`,
+		`testdata/invalid_values.yaml:21:9: unknown mock: "geo"`,
+		`testdata/invalid_values.yaml:28:9: unknown mode of array $.items: "any" (expect: ordered, unordered or contains)`,
+	}
+	if strings.Join(fixed, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Validate() =\n%s\nwant\n%s", strings.Join(fixed, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestValidate_extends(t *testing.T) {
+	var got []string
+	for _, e := range Validate("testdata/extends_invalid/suites/a.yaml", nil) {
+		got = append(got, e.Error())
+	}
+	want := []string{
+		`testdata/extends_invalid/suites/a.yaml:9:12: wrong url: parse "/users/%zz": invalid URL escape "%zz"`,
+		`testdata/extends_invalid/_base.yaml:8:5: unknown field "expcet" of TestConfig`,
+		`testdata/extends_invalid/_mocks.yaml:4:13: invalid json: unexpected end of JSON input`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Validate() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRead_strict(t *testing.T) {
+	_, err := Read("testdata/unknown_field.yaml", nil)
+	if err == nil || !strings.Contains(err.Error(), "field expcet not found") {
+		t.Errorf("Read() error = %v", err)
+	}
+}
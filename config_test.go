@@ -1,35 +0,0 @@
-package main
-
-import (
-	"reflect"
-	"testing"
-)
-
-func TestReadConfig(t *testing.T) {
-	type args struct {
-		path string
-	}
-	tests := []struct {
-		name    string
-		args    args
-		want    *Config
-		wantErr bool
-	}{
-		// TODO: Add test cases.
-		{"file not found", args{"not_found"}, nil, true},
-		{"file not found", args{"testdata/wrong_config.yaml"}, nil, true},
-		{"file not found", args{"testdata/correct_config.yaml"}, &Config{Name: "correct config"}, false},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := ReadConfig(tt.args.path)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("ReadConfig() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("ReadConfig() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
@@ -0,0 +1,157 @@
+package contract
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+)
+
+// Spec is an OpenAPI document used to check requests and responses
+type Spec struct {
+	Path string
+
+	doc       *openapi3.T
+	router    routers.Router
+	basePaths []string
+}
+
+type Request struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+}
+
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+func Load(path string) (*Spec, error) {
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+
+	doc, err := loader.LoadFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	err = doc.Validate(loader.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	// services are tested by ip, so only paths of servers are taken into account
+	s := &Spec{Path: path, doc: doc}
+	for _, srv := range doc.Servers {
+		u, err := url.Parse(srv.URL)
+		if err != nil {
+			continue
+		}
+		if p := strings.TrimRight(u.Path, "/"); p != "" {
+			s.basePaths = append(s.basePaths, p)
+		}
+	}
+	doc.Servers = nil
+
+	s.router, err = legacy.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Spec) ValidateRequest(r *Request) error {
+	input, err := s.requestInput(r)
+	if err != nil {
+		return err
+	}
+	err = openapi3filter.ValidateRequest(context.Background(), input)
+	if err != nil {
+		return fmt.Errorf("contract violation (request %s %s): %v", r.Method, r.URL, err)
+	}
+	return nil
+}
+
+func (s *Spec) ValidateResponse(r *Request, res *Response) error {
+	input, err := s.requestInput(r)
+	if err != nil {
+		return err
+	}
+
+	header := res.Header
+	if header == nil {
+		header = http.Header{}
+	}
+	resInput := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 res.Status,
+		Header:                 header,
+		Options:                input.Options,
+	}
+	resInput.SetBodyBytes(res.Body)
+
+	err = openapi3filter.ValidateResponse(context.Background(), resInput)
+	if err != nil {
+		return fmt.Errorf("contract violation (response %d on %s %s): %v", res.Status, r.Method, r.URL, err)
+	}
+	return nil
+}
+
+func (s *Spec) requestInput(r *Request) (*openapi3filter.RequestValidationInput, error) {
+	req, route, params, err := s.findRoute(r)
+	if err != nil {
+		return nil, err
+	}
+	return &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: params,
+		Route:      route,
+		Options: &openapi3filter.Options{
+			IncludeResponseStatus: true,
+			AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+		},
+	}, nil
+}
+
+func (s *Spec) findRoute(r *Request) (*http.Request, *routers.Route, map[string]string, error) {
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	paths := []string{u.Path}
+	for _, bp := range s.basePaths {
+		if strings.HasPrefix(u.Path, bp+"/") {
+			paths = append(paths, strings.TrimPrefix(u.Path, bp))
+		}
+	}
+
+	for _, p := range paths {
+		routeURL := *u
+		routeURL.Path = p
+
+		req, err := http.NewRequest(r.Method, routeURL.String(), ioutil.NopCloser(bytes.NewReader(r.Body)))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for k, vs := range r.Header {
+			req.Header[k] = vs
+		}
+
+		route, params, err := s.router.FindRoute(req)
+		if err == nil {
+			return req, route, params, nil
+		}
+	}
+	return nil, nil, nil, fmt.Errorf("contract violation: operation %s %s not found in %s", r.Method, u.Path, s.Path)
+}
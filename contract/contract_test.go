@@ -0,0 +1,94 @@
+package contract
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSpec_ValidateResponse(t *testing.T) {
+	spec, err := Load("testdata/users.yaml")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	jsonHeader := http.Header{"Content-Type": []string{"application/json"}}
+
+	tests := []struct {
+		name    string
+		req     Request
+		res     Response
+		wantErr bool
+	}{
+		{"ok", Request{Method: "GET", URL: "/users/1"},
+			Response{200, jsonHeader, []byte(`{"id": 1, "name": "mike"}`)}, false},
+		{"ok with base path", Request{Method: "GET", URL: "/v1/users/1"},
+			Response{200, jsonHeader, []byte(`{"id": 1, "name": "mike"}`)}, false},
+		{"missing field", Request{Method: "GET", URL: "/users/1"},
+			Response{200, jsonHeader, []byte(`{"id": 1}`)}, true},
+		{"undeclared status", Request{Method: "GET", URL: "/users/1"},
+			Response{404, jsonHeader, []byte(`{}`)}, true},
+		{"unknown operation", Request{Method: "DELETE", URL: "/users/1"},
+			Response{200, jsonHeader, []byte(`{}`)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := spec.ValidateResponse(&tt.req, &tt.res); (err != nil) != tt.wantErr {
+				t.Errorf("Spec.ValidateResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSpec_ValidateRequest(t *testing.T) {
+	spec, err := Load("testdata/users.yaml")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	jsonHeader := http.Header{"Content-Type": []string{"application/json"}}
+
+	tests := []struct {
+		name    string
+		req     Request
+		wantErr bool
+	}{
+		{"ok", Request{"POST", "/users", jsonHeader, []byte(`{"name": "mike"}`)}, false},
+		{"wrong body", Request{"POST", "/users", jsonHeader, []byte(`{"age": 1}`)}, true},
+		{"wrong path param", Request{"GET", "/users/mike", nil, nil}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := spec.ValidateRequest(&tt.req); (err != nil) != tt.wantErr {
+				t.Errorf("Spec.ValidateRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSpec_Example(t *testing.T) {
+	spec, err := Load("testdata/users.yaml")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		req        Request
+		wantStatus int
+		wantBody   string
+	}{
+		{"example", Request{Method: "GET", URL: "/users/1/orders"}, 200, `[{"id":7}]`},
+		{"schema sample", Request{Method: "POST", URL: "/users"}, 201, `{"created_at":"2000-01-01T00:00:00Z","id":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := spec.Example(&tt.req)
+			if err != nil {
+				t.Fatalf("Spec.Example() error = %v", err)
+			}
+			if res.Status != tt.wantStatus || string(res.Body) != tt.wantBody {
+				t.Errorf("Spec.Example() = %d %s, want %d %s", res.Status, res.Body, tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package contract
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+const maxSampleDepth = 8
+
+// Example builds a response of the operation from its examples,
+// or generates a sample from the response schema.
+func (s *Spec) Example(r *Request) (*Response, error) {
+	_, route, _, err := s.findRoute(r)
+	if err != nil {
+		return nil, err
+	}
+
+	status, ref := successResponse(route.Operation.Responses)
+	res := &Response{Status: status, Header: http.Header{}}
+	if ref == nil || ref.Value == nil || len(ref.Value.Content) == 0 {
+		return res, nil
+	}
+
+	mime, mt := mediaType(ref.Value.Content)
+	res.Header.Set("Content-Type", mime)
+
+	var v interface{}
+	switch {
+	case mt.Example != nil:
+		v = mt.Example
+	case len(mt.Examples) > 0:
+		names := make([]string, 0, len(mt.Examples))
+		for name := range mt.Examples {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if ex := mt.Examples[names[0]]; ex != nil && ex.Value != nil {
+			v = ex.Value.Value
+		}
+	case mt.Schema != nil:
+		v = sample(mt.Schema.Value, 0)
+	}
+
+	if str, ok := v.(string); ok && mime != "application/json" {
+		res.Body = []byte(str)
+		return res, nil
+	}
+	res.Body, err = json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// successResponse returns the lowest 2xx response, or the default one
+func successResponse(responses *openapi3.Responses) (int, *openapi3.ResponseRef) {
+	if responses == nil {
+		return http.StatusOK, nil
+	}
+
+	best := 0
+	for code := range responses.Map() {
+		status, err := strconv.Atoi(code)
+		if err != nil || status < 200 || status > 299 {
+			continue
+		}
+		if best == 0 || status < best {
+			best = status
+		}
+	}
+	if best != 0 {
+		return best, responses.Status(best)
+	}
+	return http.StatusOK, responses.Default()
+}
+
+func mediaType(content openapi3.Content) (string, *openapi3.MediaType) {
+	if mt := content.Get("application/json"); mt != nil {
+		return "application/json", mt
+	}
+	mimes := make([]string, 0, len(content))
+	for mime := range content {
+		mimes = append(mimes, mime)
+	}
+	sort.Strings(mimes)
+	return mimes[0], content[mimes[0]]
+}
+
+func sample(s *openapi3.Schema, depth int) interface{} {
+	if s == nil || depth > maxSampleDepth {
+		return nil
+	}
+	if s.Example != nil {
+		return s.Example
+	}
+	if s.Default != nil {
+		return s.Default
+	}
+	if len(s.Enum) > 0 {
+		return s.Enum[0]
+	}
+	if len(s.AllOf) > 0 {
+		out := map[string]interface{}{}
+		for _, ref := range s.AllOf {
+			if m, ok := sample(ref.Value, depth+1).(map[string]interface{}); ok {
+				for k, v := range m {
+					out[k] = v
+				}
+			}
+		}
+		return out
+	}
+	if len(s.OneOf) > 0 {
+		return sample(s.OneOf[0].Value, depth+1)
+	}
+	if len(s.AnyOf) > 0 {
+		return sample(s.AnyOf[0].Value, depth+1)
+	}
+
+	switch {
+	case s.Type.Is("object") || (s.Type == nil && len(s.Properties) > 0):
+		out := map[string]interface{}{}
+		for name, ref := range s.Properties {
+			out[name] = sample(ref.Value, depth+1)
+		}
+		return out
+	case s.Type.Is("array"):
+		if s.Items == nil {
+			return []interface{}{}
+		}
+		return []interface{}{sample(s.Items.Value, depth+1)}
+	case s.Type.Is("integer"), s.Type.Is("number"):
+		if s.Min != nil {
+			return *s.Min
+		}
+		return 0
+	case s.Type.Is("boolean"):
+		return false
+	case s.Type.Is("string"):
+		return sampleString(s.Format)
+	}
+	return nil
+}
+
+func sampleString(format string) string {
+	switch format {
+	case "date-time":
+		return "2000-01-01T00:00:00Z"
+	case "date":
+		return "2000-01-01"
+	case "uuid":
+		return "00000000-0000-0000-0000-000000000000"
+	case "email":
+		return "user@example.com"
+	case "uri":
+		return "http://example.com"
+	}
+	return "string"
+}
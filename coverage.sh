@@ -1,5 +1,5 @@
 #!/bin/sh
 
-go test -coverprofile=cover.out
+go test -coverprofile=cover.out ./...
 go tool cover -html=cover.out
 rm cover.out
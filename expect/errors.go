@@ -1,8 +1,9 @@
-package main
+package expect
 
 import "errors"
 
 var (
 	ErrWrongStatus        = errors.New("Wrong status")
+	ErrWrongGRPCStatus    = errors.New("Wrong grpc status")
 	ErrRequestResultIsNil = errors.New("Request result is nil")
 )
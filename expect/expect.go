@@ -0,0 +1,183 @@
+// Package expect checks responses of the tested service and requests received by mocks
+package expect
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/vkd/microtest/cmp"
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/prettylog"
+	"github.com/vkd/microtest/rpc"
+)
+
+type Expect struct {
+}
+
+func New() *Expect {
+	e := &Expect{}
+	return e
+}
+
+func (e *Expect) Check(res *Result, ex *config.ExpectConfig) error {
+	if res == nil {
+		return ErrRequestResultIsNil
+	}
+
+	if ex == nil {
+		return nil
+	}
+
+	status := ex.Status
+	if status == 0 && res.Status != 0 {
+		status = 200
+		if res.Method == config.ProtocolWebSocket {
+			status = http.StatusSwitchingProtocols
+		}
+	}
+
+	if res.GRPCStatus != "" {
+		grpcStatus := ex.GRPCStatus
+		if grpcStatus == "" {
+			grpcStatus = "OK"
+		}
+		if code, ok := rpc.ParseCode(grpcStatus); ok {
+			grpcStatus = rpc.CodeName(code)
+		}
+		if grpcStatus != res.GRPCStatus {
+			log.Printf("Wrong grpc status : %s (%s)", res.GRPCStatus, res.GRPCMessage)
+			log.Printf("Expect grpc status: %s", grpcStatus)
+			return ErrWrongGRPCStatus
+		}
+	} else if status != res.Status {
+		log.Printf("Wrong status : %d", res.Status)
+		log.Printf("Expect status: %d", status)
+		log.Printf("Response: %s", string(res.RawBody))
+		return ErrWrongStatus
+	}
+
+	if ex.Schema != nil {
+		sch, err := ex.Schema.Load()
+		if err != nil {
+			log.Printf("Error on load schema: %v", err)
+			return err
+		}
+		err = sch.Validate(res.RawBody)
+		if err != nil {
+			prettylog.H2("Error on validate body by schema")
+			log.Printf("Raw request body: %s", string(res.RawBody))
+			return err
+		}
+	}
+
+	// log.Printf("request body: %s", string(res.RawBody))
+	// log.Printf("expect  body: %s", ex.Body)
+
+	var body string
+
+	if ex.Body != "" {
+		body = ex.Body
+	} else if ex.BodyMin != "" {
+		ex.Comparator.IsLeast = true
+		body = ex.BodyMin
+	}
+
+	if len(body) > 0 {
+		err := ex.Comparator.CmpBody(res.RawBody, []byte(body))
+		if err != nil {
+			prettylog.H2("Error on compare request body")
+			log.Printf("Raw request body: %s", string(res.RawBody))
+			log.Printf("Raw expect  body: %s", body)
+			return err
+		}
+	}
+
+	if ex.Stream != nil {
+		err := checkStream(res.Messages, ex.Stream)
+		if err != nil {
+			prettylog.H2("Error on check stream messages")
+			for i, msg := range res.Messages {
+				log.Printf("Received message #%d: %s", i+1, msg)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkStream(msgs []*Message, ex *config.StreamExpectConfig) error {
+	switch ex.Mode {
+	case "", config.StreamOrdered:
+		for i := range ex.Messages {
+			if i >= len(msgs) {
+				return fmt.Errorf("stream message #%d is not received", i+1)
+			}
+			err := checkStreamMessage(msgs[i], &ex.Messages[i])
+			if err != nil {
+				return fmt.Errorf("stream message #%d: %v", i+1, err)
+			}
+		}
+	case config.StreamUnordered:
+		// a message matched by a broad expect may be the only one matching another expect
+		errs := make([]error, len(ex.Messages))
+		matchedBy, missing := cmp.MatchUnordered(len(ex.Messages), len(msgs), func(ie, ir int) bool {
+			errs[ie] = checkStreamMessage(msgs[ir], &ex.Messages[ie])
+			return errs[ie] == nil
+		})
+		if missing != -1 {
+			err := errs[missing]
+			if err == nil {
+				err = fmt.Errorf("is not received")
+			}
+			return fmt.Errorf("expected stream message #%d not found: %v", missing+1, err)
+		}
+
+		// check the chosen pairs again: overridden variables must be taken from them
+		for ir, ie := range matchedBy {
+			if ie == -1 {
+				continue
+			}
+			err := checkStreamMessage(msgs[ir], &ex.Messages[ie])
+			if err != nil {
+				return fmt.Errorf("stream message #%d: %v", ir+1, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown stream mode: %q", ex.Mode)
+	}
+	return nil
+}
+
+func checkStreamMessage(msg *Message, ex *config.StreamMessageConfig) error {
+	if ex.Event != "" && ex.Event != msg.Event {
+		return fmt.Errorf("wrong event: %q (expect: %q)", msg.Event, ex.Event)
+	}
+
+	return CheckBody(msg.Data, &ex.ExpectConfig)
+}
+
+// CheckBody checks a body of a message by schema and body (or body_min) without logs
+func CheckBody(data []byte, ex *config.ExpectConfig) error {
+	if ex.Schema != nil {
+		sch, err := ex.Schema.Load()
+		if err != nil {
+			return err
+		}
+		err = sch.Validate(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	body := ex.Body
+	if body == "" && ex.BodyMin != "" {
+		ex.Comparator.IsLeast = true
+		body = ex.BodyMin
+	}
+	if body != "" {
+		return ex.Comparator.CmpBody(data, []byte(body))
+	}
+	return nil
+}
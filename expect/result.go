@@ -0,0 +1,34 @@
+package expect
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Result is a response of the tested service or a request received by a mock
+type Result struct {
+	Method string
+	URL    string
+	Status int
+	// GRPCStatus is a code name of gRPC response, empty for http
+	GRPCStatus  string
+	GRPCMessage string
+	Header      http.Header
+	RawBody     []byte
+
+	// Messages are received by websocket or sse
+	Messages []*Message
+}
+
+// Message is a message of websocket or an event of sse
+type Message struct {
+	Event string
+	Data  []byte
+}
+
+func (m *Message) String() string {
+	if m.Event != "" {
+		return fmt.Sprintf("event: %s, data: %s", m.Event, string(m.Data))
+	}
+	return string(m.Data)
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"os/signal"
 	"path"
@@ -15,6 +14,9 @@ import (
 	"github.com/fsouza/go-dockerclient"
 
 	"log"
+
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/runner"
 )
 
 const (
@@ -22,10 +24,40 @@ const (
 	SelfDefaultImage         = "microtest:go"
 )
 
+var (
+	ContainerWorkdirPath    = "/microtest"
+	ContainerMicrotestsPath = path.Join(ContainerWorkdirPath, "microtests")
+)
+
 var (
 	isDebug  = false
+	isRecord = false
 	testPath = "./microtests"
 
+	// isValidate only checks configs of tests
+	isValidate = false
+	// isSchema prints JSON Schema of configs
+	isSchema = false
+	// isMockServe serves mocks of the config without tests
+	isMockServe = false
+
+	// runPattern and tagsFilter are --run and --tags flags
+	runPattern  = ""
+	tagsFilter  = ""
+	testsFilter *runner.Filter
+
+	// envFile and setValues are --env-file and --set flags of variables in configs
+	envFile   = ""
+	setValues []string
+	// env are variables of ${VAR} in configs
+	env *config.Env
+	// secrets are values of secrets of read configs, they are masked in log output and records
+	secrets = &config.SecretMask{}
+
+	// mocksPort and adminPort are --port and --admin-port flags of `mock serve`
+	mocksPort = ""
+	adminPort = ""
+
 	dc *docker.Client
 )
 
@@ -34,13 +66,60 @@ func parseArgs(args []string) {
 		switch args[0] {
 		case "--debug", "-debug", "debug":
 			isDebug = true
+		case "--record", "record":
+			isRecord = true
+		case "validate":
+			isValidate = true
+		case "schema":
+			isSchema = true
+		case "mock":
+			if len(args) > 1 && args[1] == "serve" {
+				isMockServe = true
+				args = args[1:]
+				break
+			}
+			testPath = args[0]
+		case "--run", "--tags", "--env-file", "--set", "--port", "--admin-port":
+			if len(args) > 1 {
+				setFlag(args[0], args[1])
+				args = args[1:]
+			}
 		default:
+			if i := strings.Index(args[0], "="); i > 0 && isValueFlag(args[0][:i]) {
+				setFlag(args[0][:i], args[0][i+1:])
+				break
+			}
 			testPath = args[0]
 		}
 		args = args[1:]
 	}
 }
 
+func isValueFlag(name string) bool {
+	switch name {
+	case "--run", "--tags", "--env-file", "--set", "--port", "--admin-port":
+		return true
+	}
+	return false
+}
+
+func setFlag(name, value string) {
+	switch name {
+	case "--run":
+		runPattern = value
+	case "--tags":
+		tagsFilter = value
+	case "--env-file":
+		envFile = value
+	case "--set":
+		setValues = append(setValues, value)
+	case "--port":
+		mocksPort = value
+	case "--admin-port":
+		adminPort = value
+	}
+}
+
 func notifySignal() <-chan struct{} {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
@@ -65,6 +144,33 @@ func main() {
 	parseArgs(os.Args[1:])
 
 	log.SetFlags(0)
+	log.SetOutput(secrets.Writer(os.Stderr))
+
+	var err error
+	testsFilter, err = runner.NewFilter(runPattern, tagsFilter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	env, err = config.NewEnv(envFile, setValues)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if isSchema {
+		bs, err := config.Schema()
+		if err != nil {
+			log.Fatalf("Error on generate schema: %v", err)
+		}
+		fmt.Println(string(bs))
+		return
+	}
+
+	if isValidate {
+		if !validateTests() {
+			os.Exit(1)
+		}
+		return
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
@@ -73,6 +179,14 @@ func main() {
 		cancel()
 	}()
 
+	if isMockServe {
+		err = serveMocks(ctx, testPath)
+		if err != nil {
+			log.Fatalf("Error on serve mocks: %v", err)
+		}
+		return
+	}
+
 	dockerClient, err := docker.NewClientFromEnv()
 	if err != nil {
 		log.Fatalf("Error on get docker client: %v", err)
@@ -139,13 +253,14 @@ func runContainer(ctx context.Context) error {
 		}
 	}()
 
-	stat, err := os.Stat(testPath)
+	// the directory of tests is mounted, a glob pattern is matched in it
+	base, testFile := config.SplitGlob(testPath)
+	stat, err := os.Stat(base)
 	if err != nil {
-		log.Printf("Error on stat path (%s): %v", testPath, err)
+		log.Printf("Error on stat path (%s): %v", base, err)
 		return err
 	}
-	hostWorkdir := absPathTests(testPath)
-	testFile := ""
+	hostWorkdir := absPathTests(base)
 	if !stat.IsDir() {
 		hostWorkdir, testFile = path.Split(hostWorkdir)
 	}
@@ -155,9 +270,27 @@ func runContainer(ctx context.Context) error {
 		cmd = append(cmd, "--debug")
 	}
 
-	bindWorkDir := fmt.Sprintf("%s:%s:ro",
+	// records are written to the tests directory
+	bindMode := ":ro"
+	if isRecord {
+		cmd = append(cmd, "--record")
+		bindMode = ""
+	}
+	if runPattern != "" {
+		cmd = append(cmd, "--run", runPattern)
+	}
+	if tagsFilter != "" {
+		cmd = append(cmd, "--tags", tagsFilter)
+	}
+	// variables of --env-file are passed with the environment
+	for _, kv := range setValues {
+		cmd = append(cmd, "--set", kv)
+	}
+
+	bindWorkDir := fmt.Sprintf("%s:%s%s",
 		hostWorkdir,
 		ContainerMicrotestsPath,
+		bindMode,
 	)
 
 	if isDebug {
@@ -169,17 +302,14 @@ func runContainer(ctx context.Context) error {
 		Config: &docker.Config{
 			Image: SelfDefaultImage,
 			Cmd:   cmd,
-			Env: []string{
-				fmt.Sprintf("%s=%s", EnvHostWorkdir, hostWorkdir),
-			},
+			Env: append(forwardedEnv(configsEnvNames(testPath)),
+				fmt.Sprintf("%s=%s", runner.EnvHostWorkdir, hostWorkdir),
+			),
 		},
 		HostConfig: &docker.HostConfig{
 			Binds: []string{
 				"/var/run/docker.sock:/var/run/docker.sock",
-				fmt.Sprintf("%s:%s:ro",
-					hostWorkdir,
-					ContainerMicrotestsPath,
-				),
+				bindWorkDir,
 			},
 		},
 	})
@@ -256,38 +386,16 @@ func startMicrotests(ctx context.Context, selfContainer *docker.Container) error
 		log.Printf("Microtests path: %s", testPath)
 	}
 
-	info, err := os.Stat(testPath)
+	tests, err := config.Find(testPath)
 	if err != nil {
 		return err
 	}
 
-	if isDebug {
-		log.Printf("Env in microtest: %v", os.Environ())
-	}
-
-	var tests []string
-
-	if info.IsDir() {
-		infos, err := ioutil.ReadDir(testPath)
-		if err != nil {
-			return err
-		}
-		for _, i := range infos {
-			if !i.IsDir() && (strings.HasSuffix(i.Name(), ".yaml") || strings.HasSuffix(i.Name(), ".yml")) {
-				tests = append(tests, path.Join(testPath, i.Name()))
-			}
-		}
-	} else {
-		tests = append(tests, testPath)
-	}
-
 	if len(tests) == 0 {
 		log.Printf("Tests not found")
 		return nil
 	}
 
-	sort.Strings(tests)
-
 	if isDebug {
 		log.Printf("Tests: %v", tests)
 	}
@@ -305,17 +413,111 @@ func startMicrotests(ctx context.Context, selfContainer *docker.Container) error
 	return nil
 }
 
+// validateTests prints problems of configs of all tests
+func validateTests() bool {
+	tests, err := config.Find(testPath)
+	if err != nil {
+		log.Printf("Error on find tests (%s): %v", testPath, err)
+		return false
+	}
+
+	count := 0
+	for _, test := range tests {
+		for _, e := range config.Validate(test, env) {
+			log.Print(e.Error())
+			count++
+		}
+	}
+	if count > 0 {
+		log.Printf("Problems: %d", count)
+		return false
+	}
+	log.Printf("Configs are valid: %d", len(tests))
+	return true
+}
+
 func startMicrotest(ctx context.Context, dc *docker.Client, selfContainer *docker.Container, configPath string) error {
-	conf, err := ReadConfig(configPath)
+	problems := config.Validate(configPath, env)
+	for _, e := range problems {
+		log.Print(e.Error())
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("config is not valid (%s): %d problems", configPath, len(problems))
+	}
+
+	conf, err := config.Read(configPath, env)
 	if err != nil {
 		log.Printf("Error on read config (%s): %v", configPath, err)
 		return err
 	}
+	secrets.Add(conf.SecretValues()...)
+
+	if testsFilter.Selected(conf) == 0 {
+		log.Printf("Skip %s: no selected tests", configPath)
+		return nil
+	}
+
+	_, err = runner.New(conf, runner.Options{
+		Docker:  dc,
+		IP:      selfContainer.NetworkSettings.IPAddress,
+		Filter:  testsFilter,
+		Record:  isRecord,
+		Debug:   isDebug,
+		Secrets: secrets,
+	}).Run(ctx)
+	return err
+}
+
+// forwardedEnvNames are variables of the host which are always passed to the microtest container
+var forwardedEnvNames = []string{"TZ"}
+
+// forwardedEnv are variables passed to the microtest container: variables of --env-file,
+// variables used by configs and forwardedEnvNames, a value of the environment overrides one of --env-file
+func forwardedEnv(names []string) []string {
+	fileVars := env.FileVars()
+	seen := map[string]bool{}
+	var keys []string
+	for _, k := range append(append([]string(nil), forwardedEnvNames...), names...) {
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	for k := range fileVars {
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+
+	var out []string
+	for _, k := range keys {
+		if v, ok := os.LookupEnv(k); ok {
+			out = append(out, k+"="+v)
+		} else if v, ok := fileVars[k]; ok {
+			out = append(out, k+"="+v)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
 
-	return (&Microtest{
-		Conf: conf,
-		IP:   selfContainer.NetworkSettings.IPAddress,
-	}).Start(ctx, dc)
+// configsEnvNames returns names of variables used by configs of tests,
+// configs which are not read are reported by the microtest container
+func configsEnvNames(p string) []string {
+	tests, err := config.Find(p)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, test := range tests {
+		names, err := config.EnvNames(test)
+		if err != nil {
+			continue
+		}
+		out = append(out, names...)
+	}
+	return out
 }
 
 func absPathTests(p string) string {
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vkd/microtest/config"
+)
+
+func TestParseArgs(t *testing.T) {
+	defer func() { runPattern, tagsFilter, testPath = "", "", "./microtests" }()
+
+	parseArgs([]string{"--run", "user", "--tags=smoke,!slow", "./tests"})
+	if runPattern != "user" || tagsFilter != "smoke,!slow" || testPath != "./tests" {
+		t.Errorf("parseArgs() = %q, %q, %q", runPattern, tagsFilter, testPath)
+	}
+}
+
+func TestParseArgs_Env(t *testing.T) {
+	defer func() { envFile, setValues, testPath = "", nil, "./microtests" }()
+
+	parseArgs([]string{"--env-file", ".env", "--set", "A=1", "--set=B=2", "./tests"})
+	if envFile != ".env" || !reflect.DeepEqual(setValues, []string{"A=1", "B=2"}) || testPath != "./tests" {
+		t.Errorf("parseArgs() = %q, %q, %q", envFile, setValues, testPath)
+	}
+}
+
+func TestParseArgs_MockServe(t *testing.T) {
+	defer func() { isMockServe, mocksPort, adminPort, testPath = false, "", "", "./microtests" }()
+
+	parseArgs([]string{"mock", "serve", "--port", "8080", "--admin-port=8081", "mocks.yaml"})
+	if !isMockServe || mocksPort != "8080" || adminPort != "8081" || testPath != "mocks.yaml" {
+		t.Errorf("parseArgs() = %v, %q, %q, %q", isMockServe, mocksPort, adminPort, testPath)
+	}
+}
+
+func TestForwardedEnv(t *testing.T) {
+	defer func(e *config.Env) { env = e }(env)
+
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	if err := ioutil.WriteFile(envFile, []byte("MT_FILE=1\nMT_OVERRIDE=file\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	env, err = config.NewEnv(envFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MT_OVERRIDE", "host")
+	t.Setenv("MT_USED", "2")
+	t.Setenv("MT_SECRET_UNUSED", "secret")
+	t.Setenv("TZ", "UTC")
+
+	got := forwardedEnv([]string{"MT_USED", "MT_UNSET"})
+	want := []string{"MT_FILE=1", "MT_OVERRIDE=host", "MT_USED=2", "TZ=UTC"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("forwardedEnv() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,206 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/expect"
+)
+
+// adminRequest is a request received by a mock in responses of the admin api
+type adminRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+func newAdminRequests(rs []*expect.Result) []adminRequest {
+	out := make([]adminRequest, 0, len(rs))
+	for _, r := range rs {
+		out = append(out, adminRequest{
+			Method:  r.Method,
+			URL:     r.URL,
+			Headers: r.Header,
+			Body:    string(r.RawBody),
+		})
+	}
+	return out
+}
+
+// adminVerify is a request of the verify endpoint, stubs-like method and url with body expects
+// select requests, count is an exact number of them (at least one by default)
+type adminVerify struct {
+	Method  string `yaml:"method"`
+	URL     string `yaml:"url"`
+	Body    string `yaml:"body"`
+	BodyMin string `yaml:"body_min"`
+	Count   *int   `yaml:"count"`
+}
+
+// AdminHandler serves the admin api of mocks:
+//
+//	GET /requests[?host=<host>]     requests received by mocks
+//	DELETE /requests[?host=<host>]  clears received requests
+//	POST /reset                     stubs of configs, received requests are cleared
+//	PUT /stubs/<host>               replaces stubs of the host by a yaml or json list
+//	POST /stubs/<host>              adds stubs to the host, they are matched before existing ones
+//	POST /verify/<host>             checks received requests, see adminVerify
+func (m *Mocks) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/requests", m.adminRequests)
+	mux.HandleFunc("/reset", m.adminReset)
+	mux.HandleFunc("/stubs/", m.adminStubs)
+	mux.HandleFunc("/verify/", m.adminVerify)
+	return mux
+}
+
+func (m *Mocks) adminRequests(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodDelete:
+		m.ClearRequests(r.URL.Query().Get("host"))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		writeAdminError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method))
+		return
+	}
+	requests := m.Requests()
+	if host := r.URL.Query().Get("host"); host != "" {
+		writeAdminJSON(w, http.StatusOK, newAdminRequests(requests[host]))
+		return
+	}
+	out := map[string][]adminRequest{}
+	for host, rs := range requests {
+		out[host] = newAdminRequests(rs)
+	}
+	writeAdminJSON(w, http.StatusOK, out)
+}
+
+func (m *Mocks) adminReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAdminError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method))
+		return
+	}
+	m.reset()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (m *Mocks) adminStubs(w http.ResponseWriter, r *http.Request) {
+	host, ok := adminHost(w, r, "/stubs/")
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		writeAdminError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method))
+		return
+	}
+	var stubs []config.MockConfig
+	err := readAdminBody(r, &stubs)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, fmt.Errorf("wrong stubs: %v", err))
+		return
+	}
+	if r.Method == http.MethodPost {
+		m.AddStubs(host, stubs)
+	} else {
+		m.SetStubs(host, stubs)
+	}
+	if m.IsDebug {
+		log.Printf("%s stubs of %q mock: %d", r.Method, host, len(stubs))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (m *Mocks) adminVerify(w http.ResponseWriter, r *http.Request) {
+	host, ok := adminHost(w, r, "/verify/")
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeAdminError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method))
+		return
+	}
+	var v adminVerify
+	err := readAdminBody(r, &v)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, fmt.Errorf("wrong verify: %v", err))
+		return
+	}
+
+	count := m.countRequests(host, &v)
+	switch {
+	case v.Count != nil && count != *v.Count:
+		err = fmt.Errorf("requests of %q mock: %d (expect: %d)", host, count, *v.Count)
+	case v.Count == nil && count == 0:
+		err = fmt.Errorf("requests of %q mock not found", host)
+	}
+	if err != nil {
+		writeAdminJSON(w, http.StatusExpectationFailed, map[string]interface{}{"count": count, "error": err.Error()})
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, map[string]interface{}{"count": count})
+}
+
+// countRequests returns count of requests of the host matched by the verify
+func (m *Mocks) countRequests(host string, v *adminVerify) int {
+	match := config.MockConfig{Method: v.Method, URL: v.URL}
+	ex := &config.ExpectConfig{Body: v.Body, BodyMin: v.BodyMin}
+
+	count := 0
+	for _, req := range m.Requests()[host] {
+		if _, errs := match.Match(req.Method, req.URL, req.RawBody); len(errs) > 0 {
+			continue
+		}
+		if expect.CheckBody(req.RawBody, ex) != nil {
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+// adminHost returns the host of the path of mock endpoints, a wrong host is written as not found
+func adminHost(w http.ResponseWriter, r *http.Request, prefix string) (string, bool) {
+	host := strings.TrimPrefix(r.URL.Path, prefix)
+	if host == "" || strings.Contains(host, "/") {
+		writeAdminError(w, http.StatusNotFound, fmt.Errorf("wrong host: %q", host))
+		return "", false
+	}
+	return host, true
+}
+
+// readAdminBody reads yaml in the format of configs, json is a subset of yaml
+func readAdminBody(r *http.Request, v interface{}) error {
+	defer r.Body.Close()
+	bs, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	return yaml.UnmarshalStrict(bs, v)
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v interface{}) {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		status, bs = http.StatusInternalServerError, []byte(fmt.Sprintf(`{"error": %q}`, err.Error()))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(bs)
+	if err != nil {
+		log.Printf("Error on write admin response: %v", err)
+	}
+}
+
+func writeAdminError(w http.ResponseWriter, status int, err error) {
+	writeAdminJSON(w, status, map[string]string{"error": err.Error()})
+}
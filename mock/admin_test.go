@@ -0,0 +1,195 @@
+package mock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/vkd/microtest/config"
+)
+
+func TestMocks_AdminHandler(t *testing.T) {
+	m := NewMocks(config.MockConfigs{"users": {Stubs: []config.MockConfig{{Method: "GET", URL: "/users/1", Out: `{"id": 1}`}}}})
+	m.ResetMocks(nil)
+	admin := m.AdminHandler()
+
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		admin.ServeHTTP(w, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return w
+	}
+	handle := func(url string) string {
+		return string(m.getMock("users").handle(httptest.NewRequest("GET", url, nil)).Body)
+	}
+
+	if w := serve("PUT", "/stubs/users", `[{method: GET, url: /users/1, body: '{"id": 2}'}]`); w.Code != http.StatusNoContent {
+		t.Fatalf("PUT /stubs/users = %d %s", w.Code, w.Body)
+	}
+	if got := handle("/users/1"); got != `{"id": 2}` {
+		t.Errorf("response of replaced stub = %s", got)
+	}
+
+	w := serve("GET", "/requests?host=users", "")
+	var requests []adminRequest
+	if err := json.Unmarshal(w.Body.Bytes(), &requests); err != nil || len(requests) != 1 || requests[0].URL != "/users/1" {
+		t.Errorf("GET /requests = %s (%v)", w.Body, err)
+	}
+
+	if w := serve("POST", "/reset", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("POST /reset = %d %s", w.Code, w.Body)
+	}
+	if got := handle("/users/1"); got != `{"id": 1}` {
+		t.Errorf("response after reset = %s", got)
+	}
+	if got := len(m.Requests()["users"]); got != 1 {
+		t.Errorf("requests after reset = %d, want 1", got)
+	}
+
+	if w := serve("PUT", "/stubs/users", `{wrong`); w.Code != http.StatusBadRequest {
+		t.Errorf("PUT /stubs/users with wrong stubs = %d", w.Code)
+	}
+}
+
+func TestMocks_adminResetTestMocks(t *testing.T) {
+	m := NewMocks(config.MockConfigs{"users": {Stubs: []config.MockConfig{{URL: "/users", Out: `[]`}}}})
+	m.ResetMocks(config.MockConfigs{"orders": {Stubs: []config.MockConfig{{URL: "/orders", Out: `[1]`}}}})
+	m.SetStubs("orders", nil)
+
+	w := httptest.NewRecorder()
+	m.AdminHandler().ServeHTTP(w, httptest.NewRequest("POST", "/reset", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("POST /reset = %d %s", w.Code, w.Body)
+	}
+	if res := m.getMock("orders").handle(httptest.NewRequest("GET", "/orders", nil)); string(res.Body) != `[1]` {
+		t.Errorf("response of test stub after reset = %d %s", res.Status, res.Body)
+	}
+}
+
+func TestMocks_AddStubsConcurrent(t *testing.T) {
+	m := NewMocks(config.MockConfigs{})
+	m.ResetMocks(nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.AddStubs("users", []config.MockConfig{{URL: "/users", Out: `[]`}})
+		}()
+	}
+	wg.Wait()
+
+	if got := len(m.getMock("users").stubs()); got != 20 {
+		t.Errorf("stubs = %d, want 20", got)
+	}
+}
+
+func TestMocks_Reload(t *testing.T) {
+	m := NewMocks(config.MockConfigs{"users": {Stubs: []config.MockConfig{{URL: "/users", Out: `[]`}}}})
+	m.ResetMocks(nil)
+	m.getMock("users").handle(httptest.NewRequest("GET", "/users", nil))
+
+	err := m.Reload(config.MockConfigs{"orders": {Stubs: []config.MockConfig{{URL: "/orders", Out: `[1]`}}}})
+	if err != nil {
+		t.Fatalf("Mocks.Reload() error = %v", err)
+	}
+
+	if res := m.getMock("users").handle(httptest.NewRequest("GET", "/users", nil)); res.Status != http.StatusInternalServerError {
+		t.Errorf("removed stub response = %d %s", res.Status, res.Body)
+	}
+	if res := m.getMock("orders").handle(httptest.NewRequest("GET", "/orders", nil)); string(res.Body) != `[1]` {
+		t.Errorf("added stub response = %d %s", res.Status, res.Body)
+	}
+	if got := len(m.Requests()["users"]); got != 2 {
+		t.Errorf("requests after reload = %d, want 2", got)
+	}
+}
+
+func TestMocks_ReloadOptions(t *testing.T) {
+	spec := "../contract/testdata/users.yaml"
+	m := NewMocks(config.MockConfigs{
+		"users":  {OpenAPI: spec},
+		"orders": {Fallback: config.MockFallbackConfig{Mode: config.FallbackDefault, Body: `[]`}},
+	})
+	if err := m.LoadSpecs(m.conf); err != nil {
+		t.Fatalf("Mocks.LoadSpecs() error = %v", err)
+	}
+	m.ResetMocks(nil)
+
+	get := func(host, url string) *mockResponse {
+		return m.getMock(host).handle(httptest.NewRequest("GET", url, nil))
+	}
+	if res := get("users", "/users/1"); res.Status != http.StatusOK {
+		t.Fatalf("openapi example response = %d %s", res.Status, res.Body)
+	}
+	if res := get("orders", "/orders"); string(res.Body) != `[]` {
+		t.Fatalf("fallback response = %d %s", res.Status, res.Body)
+	}
+
+	if err := m.Reload(config.MockConfigs{"users": {}}); err != nil {
+		t.Fatalf("Mocks.Reload() error = %v", err)
+	}
+	if res := get("users", "/users/1"); res.Status != http.StatusInternalServerError {
+		t.Errorf("response of removed openapi = %d %s", res.Status, res.Body)
+	}
+	if res := get("orders", "/orders"); res.Status != http.StatusInternalServerError {
+		t.Errorf("response of removed fallback = %d %s", res.Status, res.Body)
+	}
+
+	if err := m.Reload(config.MockConfigs{"users": {OpenAPI: "testdata/missing.yaml"}}); err == nil {
+		t.Errorf("Mocks.Reload() with missing openapi error = nil")
+	}
+}
+
+func TestMocks_handleAdmin(t *testing.T) {
+	m := NewMocks(config.MockConfigs{"users": {Stubs: []config.MockConfig{{Method: "GET", URL: "/users/{id}", Out: `{}`}}}})
+	m.ResetMocks(nil)
+
+	serve := func(method, host, url, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Host = host
+		w := httptest.NewRecorder()
+		m.handle(w, req)
+		return w
+	}
+
+	if w := serve("POST", AdminHost, "/stubs/users", `[{method: GET, url: /users/1, body: '{"id": 1}'}]`); w.Code != http.StatusNoContent {
+		t.Fatalf("POST /stubs/users = %d %s", w.Code, w.Body)
+	}
+	if w := serve("GET", "users:9001", "/users/1", ""); w.Body.String() != `{"id": 1}` {
+		t.Errorf("response of added stub = %s", w.Body)
+	}
+	serve("POST", "users", "/users/2", `{"name": "mike"}`)
+
+	tests := []struct {
+		name     string
+		verify   string
+		wantCode int
+	}{
+		{"any", `{}`, http.StatusOK},
+		{"count", `{"url": "/users/{id}", "count": 2}`, http.StatusOK},
+		{"method", `{"method": "POST", "body_min": '{"name": "mike"}'}`, http.StatusOK},
+		{"wrong count", `{"method": "GET", "count": 2}`, http.StatusExpectationFailed},
+		{"wrong body", `{"body_min": '{"name": "john"}'}`, http.StatusExpectationFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serve("POST", "users", "/__microtest/verify/users", tt.verify); w.Code != tt.wantCode {
+				t.Errorf("POST /verify/users = %d %s, want %d", w.Code, w.Body, tt.wantCode)
+			}
+		})
+	}
+
+	if w := serve("DELETE", AdminHost, "/requests?host=users", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE /requests = %d %s", w.Code, w.Body)
+	}
+	if w := serve("POST", AdminHost, "/verify/users", `{}`); w.Code != http.StatusExpectationFailed {
+		t.Errorf("POST /verify/users after clear = %d %s", w.Code, w.Body)
+	}
+	if _, ok := m.Mocks[AdminHost]; ok {
+		t.Errorf("mock of admin host is created")
+	}
+}
@@ -0,0 +1,142 @@
+package mock
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vkd/microtest/config"
+)
+
+const defaultFaultErrorStatus = http.StatusServiceUnavailable
+
+// writeMockResponse writes the response with injected faults
+func writeMockResponse(w http.ResponseWriter, res *mockResponse) error {
+	f := res.Fault
+	if f == nil {
+		return writeResponse(w, res)
+	}
+
+	// slow responses are not cut by the write timeout of the server
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	if d := faultDelay(f); d > 0 {
+		time.Sleep(d)
+	}
+
+	if f.ErrorRate > 0 && rand.Float64() < f.ErrorRate {
+		status := f.ErrorStatus
+		if status == 0 {
+			status = defaultFaultErrorStatus
+		}
+		return writeResponse(w, &mockResponse{
+			Status: status,
+			Body:   []byte("MICROTEST: INJECTED ERROR"),
+		})
+	}
+
+	switch {
+	case f.Reset:
+		conn, err := hijack(w)
+		if err != nil {
+			return err
+		}
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			// RST instead of FIN
+			_ = tcp.SetLinger(0)
+		}
+		return conn.Close()
+	case f.CloseBody:
+		conn, err := hijack(w)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		_, err = fmt.Fprintf(conn, "HTTP/1.1 %d %s\r\n", res.Status, http.StatusText(res.Status))
+		if err != nil {
+			return err
+		}
+		header := cloneHeader(res.Header)
+		header.Set("Content-Length", strconv.Itoa(len(res.Body)))
+		err = header.Write(conn)
+		if err != nil {
+			return err
+		}
+		_, err = conn.Write(append([]byte("\r\n"), res.Body[:len(res.Body)/2]...))
+		return err
+	case f.Drip > 0:
+		return writeDrip(w, res, time.Duration(f.Drip), f.DripSize)
+	}
+	return writeResponse(w, res)
+}
+
+func writeResponse(w http.ResponseWriter, res *mockResponse) error {
+	for k, vs := range res.Header {
+		w.Header()[k] = vs
+	}
+	w.WriteHeader(res.Status)
+	_, err := w.Write(res.Body)
+	return err
+}
+
+func writeDrip(w http.ResponseWriter, res *mockResponse, delay time.Duration, size int) error {
+	if size <= 0 {
+		size = 1
+	}
+	flusher, _ := w.(http.Flusher)
+
+	for k, vs := range res.Header {
+		w.Header()[k] = vs
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(res.Body)))
+	w.WriteHeader(res.Status)
+
+	for body := res.Body; len(body) > 0; {
+		n := size
+		if n > len(body) {
+			n = len(body)
+		}
+		_, err := w.Write(body[:n])
+		if err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		body = body[n:]
+		if len(body) > 0 {
+			time.Sleep(delay)
+		}
+	}
+	return nil
+}
+
+func faultDelay(f *config.MockFaultConfig) time.Duration {
+	d, max := time.Duration(f.Delay), time.Duration(f.DelayMax)
+	if max > d {
+		d += time.Duration(rand.Int63n(int64(max - d)))
+	}
+	return d
+}
+
+func hijack(w http.ResponseWriter) (net.Conn, error) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("connection can't be hijacked")
+	}
+	conn, _, err := hj.Hijack()
+	return conn, err
+}
+
+func cloneHeader(h http.Header) http.Header {
+	out := http.Header{}
+	for k, vs := range h {
+		out[k] = vs
+	}
+	return out
+}
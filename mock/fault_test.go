@@ -0,0 +1,88 @@
+package mock
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/duration"
+)
+
+func TestWriteMockResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		fault      *config.MockFaultConfig
+		wantStatus int
+		wantBody   string
+		wantErr    bool
+	}{
+		{"no fault", nil, http.StatusOK, `{"id": 1}`, false},
+		{"delay", &config.MockFaultConfig{Delay: duration.StringDuration(10 * time.Millisecond)}, http.StatusOK, `{"id": 1}`, false},
+		{"error rate", &config.MockFaultConfig{ErrorRate: 1}, http.StatusServiceUnavailable, "MICROTEST: INJECTED ERROR", false},
+		{"drip", &config.MockFaultConfig{Drip: duration.StringDuration(time.Millisecond), DripSize: 2}, http.StatusOK, `{"id": 1}`, false},
+		{"reset", &config.MockFaultConfig{Reset: true}, 0, "", true},
+		{"close body", &config.MockFaultConfig{CloseBody: true}, 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = writeMockResponse(w, &mockResponse{
+					Status: http.StatusOK,
+					Body:   []byte(`{"id": 1}`),
+					Fault:  tt.fault,
+				})
+			}))
+			defer srv.Close()
+
+			resp, err := http.Get(srv.URL)
+			if err == nil {
+				defer resp.Body.Close()
+				var body []byte
+				body, err = ioutil.ReadAll(resp.Body)
+				if err == nil && (resp.StatusCode != tt.wantStatus || string(body) != tt.wantBody) {
+					t.Errorf("response = %d %s, want %d %s", resp.StatusCode, body, tt.wantStatus, tt.wantBody)
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("response error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriteMockResponse_WriteTimeout(t *testing.T) {
+	tests := []struct {
+		name  string
+		fault *config.MockFaultConfig
+	}{
+		{"delay", &config.MockFaultConfig{Delay: duration.StringDuration(200 * time.Millisecond)}},
+		{"drip", &config.MockFaultConfig{Drip: duration.StringDuration(20 * time.Millisecond), DripSize: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = writeMockResponse(w, &mockResponse{
+					Status: http.StatusOK,
+					Body:   []byte(`{"id": 1}`),
+					Fault:  tt.fault,
+				})
+			}))
+			srv.Config.WriteTimeout = 50 * time.Millisecond
+			srv.Start()
+			defer srv.Close()
+
+			resp, err := http.Get(srv.URL)
+			if err != nil {
+				t.Fatalf("http.Get() error = %v", err)
+			}
+			defer resp.Body.Close()
+			bs, err := ioutil.ReadAll(resp.Body)
+			if err != nil || string(bs) != `{"id": 1}` {
+				t.Errorf("body = %s, error = %v", bs, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package mock
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/expect"
+	"github.com/vkd/microtest/protos"
+	"github.com/vkd/microtest/rpc"
+)
+
+// grpcMock answers unary methods by configured responses,
+// requests are messages with the method in url
+type grpcMock struct {
+	name string
+	conf *config.ProtocolMockConfig
+
+	ln      net.Listener
+	srv     *rpc.Server
+	methods map[string]config.GRPCMethodConfig
+
+	messages []*expect.Result
+	mx       sync.Mutex
+}
+
+func newGRPCMock(name string, conf *config.ProtocolMockConfig) (Protocol, error) {
+	if conf.Proto == "" {
+		return nil, fmt.Errorf("'proto' of %q grpc mock not found", name)
+	}
+	files, err := protos.Load(conf.Proto)
+	if err != nil {
+		return nil, fmt.Errorf("load proto of %q mock (%s): %v", name, conf.Proto, err)
+	}
+
+	m := &grpcMock{
+		name:    name,
+		conf:    conf,
+		methods: map[string]config.GRPCMethodConfig{},
+	}
+	for method, mc := range conf.Methods {
+		md, err := files.FindMethod(method)
+		if err != nil {
+			return nil, fmt.Errorf("method of %q mock: %v", name, err)
+		}
+		if mc.Status != "" {
+			if _, ok := rpc.ParseCode(mc.Status); !ok {
+				return nil, fmt.Errorf("unknown grpc status of %q mock (%s): %q", name, method, mc.Status)
+			}
+		}
+		m.methods[rpc.MethodName(md)] = mc
+	}
+	m.srv = rpc.NewServer(files, m.handle)
+	return m, nil
+}
+
+func (m *grpcMock) Listen(port int) error {
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	m.ln = ln
+	m.srv.Serve(ln)
+	return nil
+}
+
+func (m *grpcMock) Stop() error {
+	m.srv.Stop()
+	return nil
+}
+
+func (m *grpcMock) Reset() {
+	m.mx.Lock()
+	m.messages = nil
+	m.mx.Unlock()
+}
+
+func (m *grpcMock) Messages() []*expect.Result {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	return copyResults(m.messages)
+}
+
+func (m *grpcMock) handle(method string, body []byte) ([]byte, codes.Code, string) {
+	m.mx.Lock()
+	m.messages = append(m.messages, &expect.Result{
+		Method:  config.ProtocolGRPC,
+		URL:     method,
+		RawBody: body,
+	})
+	m.mx.Unlock()
+
+	mc, ok := m.methods[method]
+	if !ok {
+		log.Printf("Mock %q: unmatched grpc method %s, body: %s", m.name, method, string(body))
+		return nil, codes.Unimplemented, fmt.Sprintf("MICROTEST: MOCK RESPONSE %s%s NOT FOUND", m.name, method)
+	}
+
+	code := codes.OK
+	if mc.Status != "" {
+		code, _ = rpc.ParseCode(mc.Status)
+	}
+	if code != codes.OK {
+		return nil, code, mc.Message
+	}
+	return []byte(strings.TrimSpace(mc.Body)), codes.OK, ""
+}
@@ -0,0 +1,424 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/contract"
+	"github.com/vkd/microtest/expect"
+	"github.com/vkd/microtest/template"
+	"github.com/vkd/microtest/vars"
+)
+
+type Mock struct {
+	IsDebug bool
+
+	conf     []config.MockConfig
+	spec     *contract.Spec
+	upstream string
+
+	proxy    *proxy
+	recorder *recorder
+	record   bool
+
+	host string
+
+	Requests   []*expect.Result
+	mxRequests sync.Mutex
+
+	fallback config.MockFallbackConfig
+
+	vars     vars.Map
+	counters map[string]int
+
+	// failures are contract violations and unmatched requests in strict mode
+	failures []error
+	// onFailure is called on every failure
+	onFailure func()
+
+	Unmatched []*UnmatchedRequest
+}
+
+// UnmatchedRequest is a request without matched stub with the closest one
+type UnmatchedRequest struct {
+	Host    string
+	Request *expect.Result
+	Closest *config.MockConfig
+	Errors  []error
+}
+
+func (u *UnmatchedRequest) String() string {
+	var bs strings.Builder
+	fmt.Fprintf(&bs, "%s %s%s", u.Request.Method, u.Host, u.Request.URL)
+	if u.Closest == nil {
+		bs.WriteString("\n  no stubs")
+		return bs.String()
+	}
+	fmt.Fprintf(&bs, "\n  closest stub: %s", u.Closest.String())
+	for _, err := range u.Errors {
+		fmt.Fprintf(&bs, "\n  - %v", err)
+	}
+	return bs.String()
+}
+
+type mockResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+
+	Fault *config.MockFaultConfig
+}
+
+func NewMock(conf []config.MockConfig, host string) *Mock {
+	m := &Mock{
+		conf: conf,
+
+		host: host,
+	}
+	return m
+}
+
+// CheckExpect checks requests received after the offset, one of them has to match the expect
+func (m *Mock) CheckExpect(exp *config.ExpectConfig, offset int) error {
+	m.mxRequests.Lock()
+	requests := append([]*expect.Result(nil), m.Requests[clampOffset(offset, len(m.Requests)):]...)
+	m.mxRequests.Unlock()
+
+	if len(requests) == 0 {
+		return fmt.Errorf("mock requests is empty")
+	}
+	return checkAny(requests, exp)
+}
+
+// checkAny returns nil when one of results matches the expect, otherwise the error of the latest one
+func checkAny(results []*expect.Result, exp *config.ExpectConfig) error {
+	var latest error
+	for i := len(results) - 1; i >= 0; i-- {
+		err := expect.New().Check(results[i], exp)
+		if err == nil {
+			return nil
+		}
+		if latest == nil {
+			latest = err
+		}
+	}
+	return latest
+}
+
+// clampOffset returns 0 for offsets out of n, requests are cleared by resets
+func clampOffset(offset, n int) int {
+	if offset < 0 || offset > n {
+		return 0
+	}
+	return offset
+}
+
+// stubs returns stubs of the mock, they are replaced at runtime by the admin api
+func (m *Mock) stubs() []config.MockConfig {
+	m.mxRequests.Lock()
+	defer m.mxRequests.Unlock()
+	return m.conf
+}
+
+// options returns the spec, the upstream and the fallback of the mock, they are replaced by reloads
+func (m *Mock) options() (*contract.Spec, string, config.MockFallbackConfig) {
+	m.mxRequests.Lock()
+	defer m.mxRequests.Unlock()
+	return m.spec, m.upstream, m.fallback
+}
+
+func (m *Mock) setVars(vs vars.Map) {
+	m.mxRequests.Lock()
+	m.vars = vs
+	m.mxRequests.Unlock()
+}
+
+func (m *Mock) setStubs(stubs []config.MockConfig) {
+	m.mxRequests.Lock()
+	m.conf = stubs
+	m.mxRequests.Unlock()
+}
+
+// addStubs prepends stubs to stubs of the mock
+func (m *Mock) addStubs(stubs []config.MockConfig) {
+	m.mxRequests.Lock()
+	m.conf = append(append([]config.MockConfig(nil), stubs...), m.conf...)
+	m.mxRequests.Unlock()
+}
+
+// clearRequests clears received and unmatched requests
+func (m *Mock) clearRequests() {
+	m.mxRequests.Lock()
+	m.Requests = nil
+	m.Unmatched = nil
+	m.mxRequests.Unlock()
+}
+
+func (m *Mock) CheckFailures() error {
+	m.mxRequests.Lock()
+	defer m.mxRequests.Unlock()
+
+	if len(m.failures) > 0 {
+		return m.failures[0]
+	}
+	return nil
+}
+
+func (m *Mock) addFailure(err error) {
+	m.mxRequests.Lock()
+	m.failures = append(m.failures, err)
+	m.mxRequests.Unlock()
+
+	if m.onFailure != nil {
+		m.onFailure()
+	}
+}
+
+func (m *Mock) handle(r *http.Request) *mockResponse {
+	method, url := r.Method, r.URL.RequestURI()
+
+	res := &mockResponse{
+		Status: http.StatusInternalServerError,
+		Body:   []byte(fmt.Sprintf(`MICROTEST: MOCK RESPONSE %s:%s%s NOT FOUND`, method, m.host, url)),
+	}
+
+	defer r.Body.Close()
+	bodyBs, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Error on read body: %v", err)
+		return &mockResponse{Status: http.StatusOK, Body: []byte("{}")}
+	}
+
+	req := &expect.Result{
+		Method:  method,
+		URL:     url,
+		Header:  r.Header,
+		RawBody: bodyBs,
+	}
+
+	m.mxRequests.Lock()
+	m.Requests = append(m.Requests, req)
+	m.mxRequests.Unlock()
+
+	spec, upstream, fallback := m.options()
+	if spec != nil {
+		err = spec.ValidateRequest(&contract.Request{
+			Method: method,
+			URL:    url,
+			Header: r.Header,
+			Body:   bodyBs,
+		})
+		if err != nil {
+			log.Printf("Mock %q: %v", m.host, err)
+			m.addFailure(err)
+		}
+	}
+
+	if m.IsDebug {
+		stubs := m.stubs()
+		log.Printf("Start find equal out for %q", m.host)
+		log.Printf("Count configs: %d", len(stubs))
+		log.Printf("All configs: %v", stubs)
+	}
+
+	if m.record && upstream != "" {
+		proxyRes, err := m.proxy.Do(upstream, r, bodyBs)
+		if err != nil {
+			log.Printf("Error on proxy request to %q upstream (%s): %v", m.host, upstream, err)
+			res.Status = http.StatusBadGateway
+			return res
+		}
+		m.recorder.Record(m.host, method, url, proxyRes)
+		return proxyRes
+	}
+
+	found := false
+	for _, c := range m.stubs() {
+		params, errs := c.Match(method, url, bodyBs)
+		if len(errs) > 0 {
+			if m.IsDebug {
+				log.Printf("Error on check equal mock response: %v", errs[0])
+			}
+			continue
+		}
+		out, err := m.renderBody(c.Out, req, params)
+		if err != nil {
+			err = fmt.Errorf("render body of %q mock (%s %s): %v", m.host, method, url, err)
+			log.Print(err)
+			m.addFailure(err)
+			res.Body = []byte("MICROTEST: " + err.Error())
+			found = true
+			break
+		}
+		res.Status = http.StatusOK
+		if c.Status != 0 {
+			res.Status = c.Status
+		}
+		if len(c.Headers) > 0 {
+			res.Header = http.Header{}
+			for k, v := range c.Headers {
+				res.Header.Set(k, v)
+			}
+		}
+		res.Body = []byte(out)
+		res.Fault = c.Fault
+		found = true
+		break
+	}
+
+	if !found && spec != nil {
+		example, err := spec.Example(&contract.Request{Method: method, URL: url})
+		if err != nil {
+			if m.IsDebug {
+				log.Printf("Error on get openapi example: %v", err)
+			}
+		} else {
+			res.Status, res.Header, res.Body = example.Status, example.Header, example.Body
+			found = true
+		}
+	}
+
+	if !found {
+		m.addUnmatched(req)
+		res = m.handleFallback(fallback, r, req, res)
+	}
+
+	if m.IsDebug {
+		log.Printf("url mocks: %s", url)
+		log.Printf("mock host: %s", m.host)
+		log.Printf("out: %s", string(res.Body))
+	}
+
+	return res
+}
+
+// renderBody executes the body template with the incoming request:
+// {{.method}}, {{.path.<param>}}, {{.query.<key>}}, {{index .headers "<Key>"}}, {{.body}} (parsed json),
+// {{.vars.<name>}} and helpers uuid, now, json, counter
+func (m *Mock) renderBody(body string, req *expect.Result, params map[string]string) (string, error) {
+	if !strings.Contains(body, "{{") {
+		return body, nil
+	}
+
+	if params == nil {
+		params = map[string]string{}
+	}
+
+	query := map[string]string{}
+	if u, err := url.Parse(req.URL); err == nil {
+		for k, vs := range u.Query() {
+			query[k] = vs[0]
+		}
+	}
+
+	headers := map[string]string{}
+	for k := range req.Header {
+		headers[k] = req.Header.Get(k)
+	}
+
+	var reqBody interface{} = string(req.RawBody)
+	var v interface{}
+	if json.Unmarshal(req.RawBody, &v) == nil {
+		reqBody = v
+	}
+
+	m.mxRequests.Lock()
+	vs := m.vars
+	m.mxRequests.Unlock()
+	if vs == nil {
+		vs = vars.Map{}
+	}
+
+	funcs := template.Helpers()
+	funcs["counter"] = m.counter
+
+	return template.ExecuteFuncs(body, map[string]interface{}{
+		"method":  req.Method,
+		"path":    params,
+		"query":   query,
+		"headers": headers,
+		"body":    reqBody,
+		"vars":    vs,
+	}, funcs)
+}
+
+// counter returns the next value of the named counter of the mock, starting with 1
+func (m *Mock) counter(name ...string) int {
+	key := strings.Join(name, ".")
+
+	m.mxRequests.Lock()
+	defer m.mxRequests.Unlock()
+
+	if m.counters == nil {
+		m.counters = map[string]int{}
+	}
+	m.counters[key]++
+	return m.counters[key]
+}
+
+func (m *Mock) addUnmatched(req *expect.Result) {
+	u := &UnmatchedRequest{
+		Host:    m.host,
+		Request: req,
+	}
+
+	// an url mismatch is worse than a method one
+	bestScore := -1
+	stubs := m.stubs()
+	for i := range stubs {
+		errs := stubs[i].Mismatches(req.Method, req.URL, req.RawBody)
+		score := len(errs)
+		if stubs[i].EqualURL(req.URL) != nil {
+			score++
+		}
+		if bestScore == -1 || score < bestScore {
+			bestScore = score
+			u.Closest = &stubs[i]
+			u.Errors = errs
+		}
+	}
+
+	m.mxRequests.Lock()
+	m.Unmatched = append(m.Unmatched, u)
+	m.mxRequests.Unlock()
+}
+
+func (m *Mock) handleFallback(fallback config.MockFallbackConfig, r *http.Request, req *expect.Result, notFound *mockResponse) *mockResponse {
+	switch fallback.Mode {
+	case config.FallbackStrict:
+		err := fmt.Errorf("unmatched request to %q mock: %s %s, body: %s", m.host, req.Method, req.URL, string(req.RawBody))
+		log.Print(err)
+		m.addFailure(err)
+	case config.FallbackProxy:
+		res, err := m.proxy.Do(fallback.URL, r, req.RawBody)
+		if err != nil {
+			log.Printf("Error on proxy request to %q mock fallback (%s): %v", m.host, fallback.URL, err)
+			notFound.Status = http.StatusBadGateway
+			return notFound
+		}
+		return res
+	case config.FallbackDefault:
+		res := &mockResponse{
+			Status: http.StatusOK,
+			Body:   []byte(fallback.Body),
+		}
+		if fallback.Status != 0 {
+			res.Status = fallback.Status
+		}
+		if len(fallback.Headers) > 0 {
+			res.Header = http.Header{}
+			for k, v := range fallback.Headers {
+				res.Header.Set(k, v)
+			}
+		}
+		return res
+	}
+	return notFound
+}
@@ -0,0 +1,134 @@
+package mock
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/duration"
+	"github.com/vkd/microtest/retry"
+	"github.com/vkd/microtest/vars"
+)
+
+func TestMock_handleFallback(t *testing.T) {
+	tests := []struct {
+		name        string
+		fallback    config.MockFallbackConfig
+		wantStatus  int
+		wantBody    string
+		wantFailure bool
+	}{
+		{"none", config.MockFallbackConfig{}, http.StatusInternalServerError, "MICROTEST: MOCK RESPONSE GET:users/users/1 NOT FOUND", false},
+		{"strict", config.MockFallbackConfig{Mode: config.FallbackStrict}, http.StatusInternalServerError, "MICROTEST: MOCK RESPONSE GET:users/users/1 NOT FOUND", true},
+		{"default", config.MockFallbackConfig{Mode: config.FallbackDefault, Status: http.StatusNotFound, Body: `{}`}, http.StatusNotFound, `{}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMock([]config.MockConfig{{Method: "GET", URL: "/users/2", Out: `{"id": 2}`}}, "users")
+			m.fallback = tt.fallback
+
+			res := m.handle(httptest.NewRequest("GET", "/users/1", nil))
+			if res.Status != tt.wantStatus || string(res.Body) != tt.wantBody {
+				t.Errorf("Mock.handle() = %d %s, want %d %s", res.Status, res.Body, tt.wantStatus, tt.wantBody)
+			}
+			if err := m.CheckFailures(); (err != nil) != tt.wantFailure {
+				t.Errorf("Mock.CheckFailures() error = %v, wantFailure %v", err, tt.wantFailure)
+			}
+		})
+	}
+}
+
+func TestMock_Unmatched(t *testing.T) {
+	m := NewMock([]config.MockConfig{
+		{Method: "GET", URL: "/orders", Out: `[]`},
+		{Method: "GET", URL: "/users/1", Out: `{"id": 1}`},
+	}, "users")
+
+	m.handle(httptest.NewRequest("POST", "/users/1", nil))
+
+	if len(m.Unmatched) != 1 {
+		t.Fatalf("Mock.Unmatched len = %d, want 1", len(m.Unmatched))
+	}
+	u := m.Unmatched[0]
+	if u.Closest != &m.conf[1] || len(u.Errors) != 1 {
+		t.Errorf("closest = %v, errors = %v, want the second stub with wrong method", u.Closest, u.Errors)
+	}
+}
+
+func TestMock_handlePathParams(t *testing.T) {
+	m := NewMock([]config.MockConfig{{Method: "GET", URL: "/users/{id}", Out: `{"id": "{{.path.id}}"}`}}, "users")
+
+	res := m.handle(httptest.NewRequest("GET", "/users/12", nil))
+	if want := `{"id": "12"}`; res.Status != http.StatusOK || string(res.Body) != want {
+		t.Errorf("Mock.handle() = %d %s, want 200 %s", res.Status, res.Body, want)
+	}
+}
+
+func TestMock_handleTemplate(t *testing.T) {
+	m := NewMock([]config.MockConfig{{
+		Method: "POST",
+		URL:    "/users/{id}",
+		Out:    `{"id": "{{.path.id}}", "name": "{{.body.name}}", "role": "{{.query.role}}", "trace": "{{index .headers "X-Trace"}}", "token": "{{.vars.token}}", "n": {{counter}}}`,
+	}}, "users")
+	m.vars = vars.Map{"token": "abc"}
+
+	for n := 1; n <= 2; n++ {
+		req := httptest.NewRequest("POST", "/users/12?role=admin", strings.NewReader(`{"name": "mike"}`))
+		req.Header.Set("X-Trace", "t1")
+
+		res := m.handle(req)
+		want := fmt.Sprintf(`{"id": "12", "name": "mike", "role": "admin", "trace": "t1", "token": "abc", "n": %d}`, n)
+		if res.Status != http.StatusOK || string(res.Body) != want {
+			t.Errorf("Mock.handle() = %d %s, want 200 %s", res.Status, res.Body, want)
+		}
+	}
+}
+
+func TestMocks_CheckExpectRetry(t *testing.T) {
+	m := NewMocks(config.MockConfigs{"billing": {Stubs: []config.MockConfig{{URL: "/charge"}}}})
+	mock := m.getMock("billing")
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		mock.handle(httptest.NewRequest("POST", "/charge", strings.NewReader(`{"amount": 10}`)))
+	}()
+
+	err := m.CheckExpect(map[string]config.ExpectConfig{"billing": {BodyMin: `{"amount": 10}`}}, nil)
+	if err == nil {
+		t.Errorf("CheckExpect() without retry expected error")
+	}
+
+	err = m.CheckExpect(map[string]config.ExpectConfig{"billing": {
+		BodyMin: `{"amount": 10}`,
+		Retry:   &retry.Config{Timeout: duration.StringDuration(time.Second), Interval: duration.StringDuration(10 * time.Millisecond)},
+	}}, nil)
+	if err != nil {
+		t.Errorf("CheckExpect() error = %v", err)
+	}
+}
+
+func TestMocks_SetVarsSnapshot(t *testing.T) {
+	m := NewMocks(config.MockConfigs{"users": {Stubs: []config.MockConfig{{URL: "/token", Out: `{{.vars.token}}`}}}})
+	m.ResetMocks(nil)
+	vs := vars.Map{"token": "abc"}
+	m.SetVars(vs)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			vs.Add(fmt.Sprintf("v%d", i), i)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		res := m.getMock("users").handle(httptest.NewRequest("GET", "/token", nil))
+		if string(res.Body) != "abc" {
+			t.Fatalf("Mock.handle() = %s, want abc", res.Body)
+		}
+	}
+	<-done
+}
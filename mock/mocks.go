@@ -0,0 +1,526 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/contract"
+	"github.com/vkd/microtest/expect"
+	"github.com/vkd/microtest/retry"
+	"github.com/vkd/microtest/vars"
+)
+
+const (
+	DefaultMocksPort = 9001
+
+	// AdminHost is a reserved host of the admin api on the mocks port,
+	// it is also served by the /__microtest/ path of any host
+	AdminHost = "__microtest"
+)
+
+type Mocks struct {
+	Mocks map[string]*Mock
+	mx    sync.Mutex
+
+	conf  config.MockConfigs
+	specs map[string]*contract.Spec
+
+	// testConf are mocks of the running test, resets of the admin api keep them
+	testConf config.MockConfigs
+
+	proxy    *proxy
+	recorder *recorder
+
+	vars vars.Map
+
+	protocols map[string]Protocol
+
+	// IsDebug enables verbose logs of mocks, it is applied to mocks by ResetMocks
+	IsDebug bool
+	Port    int
+	// Secrets are masked in records of mocks
+	Secrets *config.SecretMask
+
+	// Record proxies requests of hosts with upstream and records them
+	Record bool
+
+	srv   *http.Server
+	admin http.Handler
+
+	onFailure   func()
+	mxOnFailure sync.Mutex
+}
+
+func NewMocks(conf config.MockConfigs) *Mocks {
+	m := &Mocks{
+		Mocks: map[string]*Mock{},
+		conf:  conf,
+		specs: map[string]*contract.Spec{},
+
+		proxy:    newProxy(),
+		recorder: newRecorder(config.RedactConfig{}),
+
+		protocols: map[string]Protocol{},
+
+		Port: DefaultMocksPort,
+	}
+	m.admin = m.AdminHandler()
+	m.UpdateConfigs(conf)
+	return m
+}
+
+// ResetMocks clears requests and stubs set at runtime, mocks get stubs of the config with ones of conf
+func (m *Mocks) ResetMocks(conf config.MockConfigs) {
+	m.mx.Lock()
+	m.Mocks = map[string]*Mock{}
+	m.testConf = conf
+	base := m.conf
+	m.mx.Unlock()
+
+	m.UpdateConfigs(base)
+	m.UpdateConfigs(conf)
+
+	for _, pm := range m.protocols {
+		pm.Reset()
+	}
+}
+
+// reset resets mocks keeping mocks of the last ResetMocks
+func (m *Mocks) reset() {
+	m.mx.Lock()
+	conf := m.testConf
+	m.mx.Unlock()
+
+	m.ResetMocks(conf)
+}
+
+// RunProtocolMocks starts mocks of non-HTTP upstreams
+func (m *Mocks) RunProtocolMocks(conf map[string]config.ProtocolMockConfig) error {
+	for name, c := range conf {
+		if c.Port == 0 || c.Port == m.Port {
+			return fmt.Errorf("wrong port of %q mock: %d", name, c.Port)
+		}
+		pm, err := NewProtocol(name, &c)
+		if err != nil {
+			return err
+		}
+		if d, ok := pm.(debugger); ok {
+			d.setDebug(m.IsDebug)
+		}
+		if m.IsDebug {
+			log.Printf("Mock %q listen port: %d", name, c.Port)
+		}
+		err = pm.Listen(c.Port)
+		if err != nil {
+			log.Printf("Error on listen %q mock: %v", name, err)
+			return err
+		}
+		m.protocols[name] = pm
+	}
+	return nil
+}
+
+// LoadSpecs loads OpenAPI specs of mock hosts
+func (m *Mocks) LoadSpecs(confs ...config.MockConfigs) error {
+	m.mx.Lock()
+	loaded := m.specs
+	m.mx.Unlock()
+
+	specs, err := loadSpecs(loaded, confs...)
+	if err != nil {
+		return err
+	}
+
+	m.mx.Lock()
+	m.specs = specs
+	m.mx.Unlock()
+	return nil
+}
+
+// loadSpecs returns specs of mock hosts with loaded ones, which are not read again
+func loadSpecs(loaded map[string]*contract.Spec, confs ...config.MockConfigs) (map[string]*contract.Spec, error) {
+	specs := map[string]*contract.Spec{}
+	for path, spec := range loaded {
+		specs[path] = spec
+	}
+	for _, conf := range confs {
+		for mockName, c := range conf {
+			if c.OpenAPI == "" || specs[c.OpenAPI] != nil {
+				continue
+			}
+			spec, err := contract.Load(c.OpenAPI)
+			if err != nil {
+				log.Printf("Error on load openapi spec of %q mock (%s): %v", mockName, c.OpenAPI, err)
+				return nil, err
+			}
+			specs[c.OpenAPI] = spec
+		}
+	}
+	return specs, nil
+}
+
+func (m *Mocks) UpdateConfigs(conf config.MockConfigs) {
+	m.mx.Lock()
+
+	for mockName, c := range conf {
+		if m.IsDebug {
+			log.Printf("Update mock (%s): %v", mockName, c)
+		}
+		if mm, ok := m.Mocks[mockName]; ok {
+			mm.setStubs(c.Stubs)
+			m.setHostConfig(mm, c)
+		} else {
+			m.Mocks[mockName] = m.newMock(mockName, c)
+		}
+	}
+
+	m.mx.Unlock()
+}
+
+func (m *Mocks) newMock(host string, c config.MockHostConfig) *Mock {
+	mock := NewMock(c.Stubs, host)
+	mock.proxy = m.proxy
+	mock.recorder = m.recorder
+	mock.vars = m.vars
+	mock.record = m.Record
+	mock.IsDebug = m.IsDebug
+	mock.onFailure = m.notifyFailure
+	m.setHostConfig(mock, c)
+	return mock
+}
+
+// NotifyFailures calls f on every failure of mocks (a contract violation or an unmatched request in strict mode)
+// until stop is called, so the running request is failed without waiting for its response
+func (m *Mocks) NotifyFailures(f func()) (stop func()) {
+	m.mxOnFailure.Lock()
+	m.onFailure = f
+	m.mxOnFailure.Unlock()
+
+	return func() {
+		m.mxOnFailure.Lock()
+		m.onFailure = nil
+		m.mxOnFailure.Unlock()
+	}
+}
+
+func (m *Mocks) notifyFailure() {
+	m.mxOnFailure.Lock()
+	f := m.onFailure
+	m.mxOnFailure.Unlock()
+
+	if f != nil {
+		f()
+	}
+}
+
+// setHostConfig sets options of the host config over options of the mock, empty ones are kept
+func (m *Mocks) setHostConfig(mock *Mock, c config.MockHostConfig) {
+	mock.mxRequests.Lock()
+	defer mock.mxRequests.Unlock()
+
+	if c.OpenAPI != "" {
+		mock.spec = m.specs[c.OpenAPI]
+	}
+	if c.Upstream != "" {
+		mock.upstream = c.Upstream
+	}
+	if c.Fallback.Mode != "" {
+		mock.fallback = c.Fallback
+	}
+}
+
+// Reload replaces configs of mocks keeping received requests, specs are read again,
+// options and stubs of hosts are replaced by ones of the config, so removed ones are cleared
+func (m *Mocks) Reload(conf config.MockConfigs) error {
+	specs, err := loadSpecs(nil, conf)
+	if err != nil {
+		return err
+	}
+
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	m.conf = conf
+	m.specs = specs
+	for host, mock := range m.Mocks {
+		c := conf[host]
+		mock.mxRequests.Lock()
+		mock.conf = c.Stubs
+		mock.spec = specs[c.OpenAPI]
+		mock.upstream = c.Upstream
+		mock.fallback = c.Fallback
+		mock.mxRequests.Unlock()
+	}
+	for host, c := range conf {
+		if _, ok := m.Mocks[host]; !ok {
+			m.Mocks[host] = m.newMock(host, c)
+		}
+	}
+	return nil
+}
+
+// SetStubs replaces stubs of the host until the next reset
+func (m *Mocks) SetStubs(host string, stubs []config.MockConfig) {
+	m.getMock(host).setStubs(stubs)
+}
+
+// AddStubs adds stubs to the host, they are matched before existing ones until the next reset
+func (m *Mocks) AddStubs(host string, stubs []config.MockConfig) {
+	m.getMock(host).addStubs(stubs)
+}
+
+// ClearRequests clears requests received by the mock of the host, by all mocks for the empty host
+func (m *Mocks) ClearRequests(host string) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	for h, mock := range m.Mocks {
+		if host == "" || host == h {
+			mock.clearRequests()
+		}
+	}
+}
+
+// Requests returns requests received by mocks by their hosts
+func (m *Mocks) Requests() map[string][]*expect.Result {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	out := map[string][]*expect.Result{}
+	for host, mock := range m.Mocks {
+		mock.mxRequests.Lock()
+		if len(mock.Requests) > 0 {
+			out[host] = append([]*expect.Result(nil), mock.Requests...)
+		}
+		mock.mxRequests.Unlock()
+	}
+	return out
+}
+
+// SetVars sets test variables available in body templates of mocks,
+// mocks get a copy, so it is called again after variables are changed
+func (m *Mocks) SetVars(vs vars.Map) {
+	snapshot := vars.Map{}
+	snapshot.Merge(vs)
+
+	m.mx.Lock()
+	m.vars = snapshot
+	for _, mock := range m.Mocks {
+		mock.setVars(snapshot)
+	}
+	m.mx.Unlock()
+}
+
+// SetRecordRedact sets options of written records
+func (m *Mocks) SetRecordRedact(redact config.RedactConfig) {
+	m.recorder.redact = redact
+}
+
+// SetHost resolves the hostname of a started service for proxied requests
+func (m *Mocks) SetHost(name, ip string) {
+	m.proxy.SetHost(name, ip)
+}
+
+// WriteRecords saves stubs recorded by `microtest record` to replay files of mocks of confs
+func (m *Mocks) WriteRecords(confs ...config.MockConfigs) error {
+	return m.recorder.Write(m.Secrets, confs...)
+}
+
+// Run listens on Port (a random one for 0, Port is set to it) and serves requests to mocks,
+// a mock is chosen by the host of request
+func (m *Mocks) Run() error {
+	if m.srv != nil {
+		err := m.srv.Shutdown(context.Background())
+		if err != nil {
+			return err
+		}
+	}
+
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", m.Port))
+	if err != nil {
+		return err
+	}
+	m.Port = ln.Addr().(*net.TCPAddr).Port
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", m.handle)
+
+	srv := &http.Server{
+		Handler: mux,
+
+		ReadTimeout:       5 * time.Second,
+		WriteTimeout:      5 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	m.srv = srv
+	go func() {
+		if m.IsDebug {
+			log.Printf("Mocks listen port: %d", m.Port)
+		}
+		err := srv.Serve(ln)
+		if err != nil {
+			if err == http.ErrServerClosed {
+				return
+			}
+			log.Printf("Error on listen mocks: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (m *Mocks) Stop() error {
+	for name, pm := range m.protocols {
+		err := pm.Stop()
+		if err != nil {
+			log.Printf("Error on stop %q mock: %v", name, err)
+		}
+	}
+	if m.srv != nil {
+		return m.srv.Shutdown(context.Background())
+	}
+	return nil
+}
+
+// Offsets returns counts of requests received by mocks and messages of protocol mocks by their names,
+// they scope expects of CheckExpect to requests of one step
+func (m *Mocks) Offsets() map[string]int {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	out := map[string]int{}
+	for host, mock := range m.Mocks {
+		mock.mxRequests.Lock()
+		out[host] = len(mock.Requests)
+		mock.mxRequests.Unlock()
+	}
+	for name, pm := range m.protocols {
+		out[name] = len(pm.Messages())
+	}
+	return out
+}
+
+// CheckExpect checks requests of mocks received after offsets (all requests for nil offsets),
+// one of requests of every mock has to match its expect
+func (m *Mocks) CheckExpect(exp map[string]config.ExpectConfig, offsets map[string]int) error {
+	for mockName, e := range exp {
+		e := e
+		if pm, ok := m.protocols[mockName]; ok {
+			err := retry.Do(e.Retry, func() error {
+				return checkProtocolMockExpect(mockName, pm, &e, offsets[mockName])
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		mock := m.getMock(mockName)
+		if mock == nil {
+			return fmt.Errorf("mock not found: %q", mockName)
+		}
+
+		// requests of asynchronous calls may be received after the response
+		err := retry.Do(e.Retry, func() error {
+			return mock.CheckExpect(&e, offsets[mockName])
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CheckFailures returns the first contract violation or unmatched request in strict mode
+func (m *Mocks) CheckFailures() error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	for _, mock := range m.Mocks {
+		err := mock.CheckFailures()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unmatched returns requests without matched stubs of all mocks
+func (m *Mocks) Unmatched() []*UnmatchedRequest {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	hosts := make([]string, 0, len(m.Mocks))
+	for host := range m.Mocks {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	var out []*UnmatchedRequest
+	for _, host := range hosts {
+		mock := m.Mocks[host]
+		mock.mxRequests.Lock()
+		out = append(out, mock.Unmatched...)
+		mock.mxRequests.Unlock()
+	}
+	return out
+}
+
+func (m *Mocks) getMock(host string) *Mock {
+	m.mx.Lock()
+	mock := m.Mocks[host]
+	if mock == nil {
+		log.Printf("Create new mock: %q", host)
+		mock = m.newMock(host, m.conf[host])
+		m.Mocks[host] = mock
+	}
+	m.mx.Unlock()
+	return mock
+}
+
+func (m *Mocks) handle(w http.ResponseWriter, r *http.Request) {
+	host := trimHost(r.Host)
+	if host == AdminHost {
+		m.admin.ServeHTTP(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/"+AdminHost+"/") {
+		http.StripPrefix("/"+AdminHost, m.admin).ServeHTTP(w, r)
+		return
+	}
+
+	mock := m.getMock(host)
+
+	if mock == nil {
+		m.defaultHandle(w, r)
+	}
+
+	res := mock.handle(r)
+	err := writeMockResponse(w, res)
+	if err != nil {
+		log.Printf("Error on write response to host %q: %v", r.Host, err)
+	}
+}
+
+func (m *Mocks) defaultHandle(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusInternalServerError)
+	_, err := w.Write([]byte(`{"error": "mock_not_found", "result": null}`))
+	if err != nil {
+		log.Printf("Error on write default response to host %q: %v", r.Host, err)
+	}
+}
+
+func trimHost(host string) string {
+	if i := strings.Index(host, ":"); i > 0 {
+		return host[:i]
+	}
+	return host
+}
@@ -0,0 +1,278 @@
+package mock
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/expect"
+)
+
+// Protocol is a mock of a non-HTTP upstream listening on its own port,
+// received messages are checked by expect.mocks as json bodies
+type Protocol interface {
+	Listen(port int) error
+	Stop() error
+	// Reset clears received messages
+	Reset()
+	Messages() []*expect.Result
+}
+
+type ProtocolFactory func(name string, conf *config.ProtocolMockConfig) (Protocol, error)
+
+// debugger is a protocol mock with verbose logs
+type debugger interface {
+	setDebug(isDebug bool)
+}
+
+var (
+	protocolMocks   = map[string]ProtocolFactory{}
+	mxProtocolMocks sync.Mutex
+)
+
+func init() {
+	RegisterProtocol(config.ProtocolSMTP, newSMTPMock)
+	RegisterProtocol(config.ProtocolTCP, newTCPScriptMock)
+	RegisterProtocol(config.ProtocolGRPC, newGRPCMock)
+}
+
+// RegisterProtocol adds a protocol available in protocol_mocks
+func RegisterProtocol(protocol string, f ProtocolFactory) {
+	mxProtocolMocks.Lock()
+	protocolMocks[protocol] = f
+	mxProtocolMocks.Unlock()
+}
+
+func NewProtocol(name string, conf *config.ProtocolMockConfig) (Protocol, error) {
+	mxProtocolMocks.Lock()
+	f, ok := protocolMocks[conf.Protocol]
+	mxProtocolMocks.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown protocol of %q mock: %q", name, conf.Protocol)
+	}
+	return f(name, conf)
+}
+
+// checkProtocolMockExpect checks messages received after the offset, one of them has to match the expect
+func checkProtocolMockExpect(name string, pm Protocol, exp *config.ExpectConfig, offset int) error {
+	msgs := pm.Messages()
+	msgs = msgs[clampOffset(offset, len(msgs)):]
+	if len(msgs) == 0 {
+		return fmt.Errorf("mock %q messages is empty", name)
+	}
+	return checkAny(msgs, exp)
+}
+
+// tcpServer accepts connections and keeps received messages
+type tcpServer struct {
+	name    string
+	isDebug bool
+
+	ln    net.Listener
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+
+	messages []*expect.Result
+	mx       sync.Mutex
+}
+
+func (s *tcpServer) setDebug(isDebug bool) {
+	s.isDebug = isDebug
+}
+
+func (s *tcpServer) listen(port int, serve func(net.Conn)) error {
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	s.ln = ln
+	s.conns = map[net.Conn]struct{}{}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mx.Lock()
+			s.conns[conn] = struct{}{}
+			s.mx.Unlock()
+
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer func() {
+					conn.Close()
+					s.mx.Lock()
+					delete(s.conns, conn)
+					s.mx.Unlock()
+				}()
+				serve(conn)
+			}()
+		}
+	}()
+	return nil
+}
+
+func (s *tcpServer) Stop() error {
+	if s.ln == nil {
+		return nil
+	}
+	err := s.ln.Close()
+
+	s.mx.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mx.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *tcpServer) Reset() {
+	s.mx.Lock()
+	s.messages = nil
+	s.mx.Unlock()
+}
+
+// Messages returns copies of received messages
+func (s *tcpServer) Messages() []*expect.Result {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return copyResults(s.messages)
+}
+
+func (s *tcpServer) addMessage(v interface{}) *expect.Result {
+	body, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Error on marshal message of %q mock: %v", s.name, err)
+	}
+	res := &expect.Result{RawBody: body}
+
+	s.mx.Lock()
+	s.messages = append(s.messages, res)
+	s.mx.Unlock()
+	return res
+}
+
+// setMessage replaces the received message by a new one and returns it,
+// published messages are not changed, they are read by checks of expects,
+// the message is added again when messages are reset after it was received
+func (s *tcpServer) setMessage(old *expect.Result, v interface{}) *expect.Result {
+	body, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Error on marshal message of %q mock: %v", s.name, err)
+	}
+	res := &expect.Result{RawBody: body}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for i := range s.messages {
+		if s.messages[i] == old {
+			s.messages[i] = res
+			return res
+		}
+	}
+	s.messages = append(s.messages, res)
+	return res
+}
+
+// copyResults returns copies of results with copied bodies
+func copyResults(results []*expect.Result) []*expect.Result {
+	out := make([]*expect.Result, len(results))
+	for i, res := range results {
+		c := *res
+		c.RawBody = append([]byte(nil), res.RawBody...)
+		out[i] = &c
+	}
+	return out
+}
+
+// tcpScriptMock answers received lines by the first matched step of the script,
+// lines of every connection are a message: {"lines": [...]}
+type tcpScriptMock struct {
+	tcpServer
+
+	conf  *config.ProtocolMockConfig
+	steps []tcpScriptStep
+}
+
+type tcpScriptStep struct {
+	config.TCPScriptStepConfig
+	re *regexp.Regexp
+}
+
+func newTCPScriptMock(name string, conf *config.ProtocolMockConfig) (Protocol, error) {
+	m := &tcpScriptMock{
+		tcpServer: tcpServer{name: name},
+		conf:      conf,
+	}
+	for _, step := range conf.Script {
+		s := tcpScriptStep{TCPScriptStepConfig: step}
+		if strings.HasPrefix(step.Expect, config.RegexpPrefix) {
+			re, err := regexp.Compile(step.Expect[len(config.RegexpPrefix):])
+			if err != nil {
+				return nil, fmt.Errorf("Error on compile script regexp of %q mock (%s): %v", name, step.Expect, err)
+			}
+			s.re = re
+		}
+		m.steps = append(m.steps, s)
+	}
+	return m, nil
+}
+
+func (m *tcpScriptMock) Listen(port int) error {
+	return m.listen(port, m.serve)
+}
+
+func (m *tcpScriptMock) serve(conn net.Conn) {
+	if m.conf.Greeting != "" {
+		fmt.Fprintf(conn, "%s\r\n", m.conf.Greeting)
+	}
+
+	lines := []string{}
+	msg := m.addMessage(map[string]interface{}{"lines": lines})
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		lines = append(lines, line)
+		msg = m.setMessage(msg, map[string]interface{}{"lines": lines})
+
+		step, ok := m.match(line)
+		if !ok {
+			if m.isDebug {
+				log.Printf("Mock %q: unmatched line %q", m.name, line)
+			}
+			continue
+		}
+		if step.Send != "" {
+			_, err := fmt.Fprintf(conn, "%s\r\n", step.Send)
+			if err != nil {
+				return
+			}
+		}
+		if step.Close {
+			return
+		}
+	}
+}
+
+func (m *tcpScriptMock) match(line string) (tcpScriptStep, bool) {
+	for _, s := range m.steps {
+		if s.re != nil && s.re.MatchString(line) {
+			return s, true
+		}
+		if s.re == nil && (s.Expect == "" || s.Expect == line) {
+			return s, true
+		}
+	}
+	return tcpScriptStep{}, false
+}
@@ -0,0 +1,209 @@
+package mock
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/duration"
+	"github.com/vkd/microtest/retry"
+)
+
+func TestSMTPMock(t *testing.T) {
+	pm, err := NewProtocol("mailer", &config.ProtocolMockConfig{Protocol: config.ProtocolSMTP})
+	if err != nil {
+		t.Fatalf("NewProtocol() error = %v", err)
+	}
+	if err = pm.Listen(0); err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer pm.Stop()
+
+	addr := pm.(*smtpMock).ln.Addr().String()
+	msg := "Subject: Welcome\r\nX-Id: 1\r\n\r\nHello, Mike\r\n"
+	err = smtp.SendMail(addr, nil, "noreply@example.com", []string{"mike@example.com"}, []byte(msg))
+	if err != nil {
+		t.Fatalf("smtp.SendMail() error = %v", err)
+	}
+
+	err = checkProtocolMockExpect("mailer", pm, &config.ExpectConfig{
+		Body: `{"from": "noreply@example.com", "to": ["mike@example.com"], "subject": "Welcome",
+			"headers": {"Subject": "Welcome", "X-Id": "1"}, "body": "Hello, Mike\n"}`,
+	}, 0)
+	if err != nil {
+		t.Errorf("checkProtocolMockExpect() error = %v", err)
+	}
+}
+
+func TestTCPScriptMock(t *testing.T) {
+	pm, err := NewProtocol("legacy", &config.ProtocolMockConfig{
+		Protocol: config.ProtocolTCP,
+		Greeting: "HELLO",
+		Script: []config.TCPScriptStepConfig{
+			{Expect: "PING", Send: "PONG"},
+			{Expect: `~^GET \w+$`, Send: "VALUE 1"},
+			{Expect: "BYE", Close: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewProtocol() error = %v", err)
+	}
+	if err = pm.Listen(0); err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer pm.Stop()
+
+	conn, err := net.Dial("tcp", pm.(*tcpScriptMock).ln.Addr().String())
+	if err != nil {
+		t.Fatalf("net.Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	var got []string
+	read := func() {
+		line, _ := r.ReadString('\n')
+		got = append(got, strings.TrimSpace(line))
+	}
+
+	read()
+	conn.Write([]byte("PING\r\n"))
+	read()
+	conn.Write([]byte("GET key\r\n"))
+	read()
+	conn.Write([]byte("BYE\r\n"))
+	if _, err := r.ReadString('\n'); err == nil {
+		t.Errorf("connection is not closed")
+	}
+
+	if want := "HELLO,PONG,VALUE 1"; strings.Join(got, ",") != want {
+		t.Errorf("received = %v, want %v", got, want)
+	}
+
+	err = checkProtocolMockExpect("legacy", pm, &config.ExpectConfig{Body: `{"lines": ["PING", "GET key", "BYE"]}`}, 0)
+	if err != nil {
+		t.Errorf("checkProtocolMockExpect() error = %v", err)
+	}
+}
+
+func TestTCPScriptMock_MessagesConcurrent(t *testing.T) {
+	pm, err := NewProtocol("legacy", &config.ProtocolMockConfig{Protocol: config.ProtocolTCP})
+	if err != nil {
+		t.Fatalf("NewProtocol() error = %v", err)
+	}
+	if err = pm.Listen(0); err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer pm.Stop()
+
+	conn, err := net.Dial("tcp", pm.(*tcpScriptMock).ln.Addr().String())
+	if err != nil {
+		t.Fatalf("net.Dial() error = %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			conn.Write([]byte("PING\r\n"))
+		}
+		conn.Close()
+	}()
+
+	// messages are read while lines are received, the race detector checks them
+	for {
+		for _, msg := range pm.Messages() {
+			_ = string(msg.RawBody)
+		}
+		select {
+		case <-done:
+		default:
+			continue
+		}
+		break
+	}
+
+	err = retry.Do(&retry.Config{Timeout: duration.StringDuration(time.Second), Interval: duration.StringDuration(10 * time.Millisecond)}, func() error {
+		msgs := pm.Messages()
+		if len(msgs) != 1 || strings.Count(string(msgs[0].RawBody), "PING") != 100 {
+			return fmt.Errorf("messages = %v", msgs)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSMTPMock_Auth(t *testing.T) {
+	pm, err := NewProtocol("mailer", &config.ProtocolMockConfig{Protocol: config.ProtocolSMTP, Username: "app", Password: "secret"})
+	if err != nil {
+		t.Fatalf("NewProtocol() error = %v", err)
+	}
+	if err = pm.Listen(0); err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer pm.Stop()
+
+	addr := fmt.Sprintf("127.0.0.1:%d", pm.(*smtpMock).ln.Addr().(*net.TCPAddr).Port)
+	msg := []byte("Subject: Welcome\r\n\r\nHello\r\n")
+	tests := []struct {
+		name    string
+		auth    smtp.Auth
+		wantErr bool
+	}{
+		{"valid", smtp.PlainAuth("", "app", "secret", "127.0.0.1"), false},
+		{"wrong password", smtp.PlainAuth("", "app", "wrong", "127.0.0.1"), true},
+		{"without auth", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := smtp.SendMail(addr, tt.auth, "noreply@example.com", []string{"mike@example.com"}, msg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("smtp.SendMail() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	if got := len(pm.Messages()); got != 1 {
+		t.Errorf("messages = %d, want 1", got)
+	}
+}
+
+func TestTCPScriptMock_Reset(t *testing.T) {
+	pm, err := NewProtocol("legacy", &config.ProtocolMockConfig{
+		Protocol: config.ProtocolTCP,
+		Script:   []config.TCPScriptStepConfig{{Expect: "PING", Send: "PONG"}},
+	})
+	if err != nil {
+		t.Fatalf("NewProtocol() error = %v", err)
+	}
+	if err = pm.Listen(0); err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer pm.Stop()
+
+	conn, err := net.Dial("tcp", pm.(*tcpScriptMock).ln.Addr().String())
+	if err != nil {
+		t.Fatalf("net.Dial() error = %v", err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	conn.Write([]byte("PING\r\n"))
+	r.ReadString('\n')
+
+	// the connection is opened before the reset of the next test
+	pm.Reset()
+	conn.Write([]byte("PING\r\n"))
+	r.ReadString('\n')
+
+	err = checkProtocolMockExpect("legacy", pm, &config.ExpectConfig{Body: `{"lines": ["PING", "PING"]}`}, 0)
+	if err != nil {
+		t.Errorf("checkProtocolMockExpect() error = %v", err)
+	}
+}
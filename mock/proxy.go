@@ -0,0 +1,231 @@
+package mock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/vkd/microtest/config"
+)
+
+const redacted = "REDACTED"
+
+var skipRecordHeaders = map[string]struct{}{
+	"Date":              {},
+	"Content-Length":    {},
+	"Connection":        {},
+	"Keep-Alive":        {},
+	"Transfer-Encoding": {},
+}
+
+// proxy sends requests of mocks to real services,
+// hostnames of started services are resolved to their ips
+type proxy struct {
+	client *http.Client
+
+	hosts   map[string]string
+	mxHosts sync.Mutex
+}
+
+func newProxy() *proxy {
+	p := &proxy{hosts: map[string]string{}}
+
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	p.client = &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, p.resolve(addr))
+			},
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return p
+}
+
+func (p *proxy) SetHost(name, ip string) {
+	p.mxHosts.Lock()
+	p.hosts[name] = ip
+	p.mxHosts.Unlock()
+}
+
+func (p *proxy) resolve(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	p.mxHosts.Lock()
+	defer p.mxHosts.Unlock()
+	if ip, ok := p.hosts[host]; ok {
+		return net.JoinHostPort(ip, port)
+	}
+	return addr
+}
+
+func (p *proxy) Do(baseURL string, r *http.Request, body []byte) (*mockResponse, error) {
+	req, err := http.NewRequest(r.Method, strings.TrimRight(baseURL, "/")+r.URL.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range r.Header {
+		req.Header[k] = vs
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	out, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	for k, vs := range resp.Header {
+		if _, skip := skipRecordHeaders[k]; !skip {
+			header[k] = vs
+		}
+	}
+
+	return &mockResponse{
+		Status: resp.StatusCode,
+		Header: header,
+		Body:   out,
+	}, nil
+}
+
+// recorder collects proxied exchanges as stubs
+type recorder struct {
+	redact config.RedactConfig
+
+	stubs map[string][]config.MockConfig
+	mx    sync.Mutex
+}
+
+func newRecorder(redact config.RedactConfig) *recorder {
+	return &recorder{
+		redact: redact,
+		stubs:  map[string][]config.MockConfig{},
+	}
+}
+
+func (r *recorder) Record(host, method, url string, res *mockResponse) {
+	stub := config.MockConfig{
+		Method: method,
+		URL:    url,
+		Status: res.Status,
+		Out:    string(r.redactBody(res.Body)),
+	}
+	if len(res.Header) > 0 {
+		stub.Headers = map[string]string{}
+		for k := range res.Header {
+			stub.Headers[k] = res.Header.Get(k)
+			for _, h := range r.redact.Headers {
+				if http.CanonicalHeaderKey(h) == k {
+					stub.Headers[k] = redacted
+				}
+			}
+		}
+	}
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	// stubs are matched in order, so only the first exchange of a request is replayed
+	for _, s := range r.stubs[host] {
+		if s.Method == method && s.URL == url {
+			return
+		}
+	}
+	r.stubs[host] = append(r.stubs[host], stub)
+}
+
+func (r *recorder) redactBody(body []byte) []byte {
+	if len(r.redact.Fields) == 0 {
+		return body
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+	out, err := json.Marshal(r.redactValue(v))
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+func (r *recorder) redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			v[k] = r.redactValue(val)
+			for _, f := range r.redact.Fields {
+				if f == k {
+					v[k] = redacted
+				}
+			}
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = r.redactValue(val)
+		}
+	}
+	return v
+}
+
+// Write saves recorded stubs of every host to the replay file of its config with upstream,
+// configs are the global one and ones of tests, secrets (if any) are masked
+func (r *recorder) Write(secrets *config.SecretMask, confs ...config.MockConfigs) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	var errNoReplay error
+	for host, stubs := range r.stubs {
+		path := replayPath(host, confs)
+		if path == "" {
+			errNoReplay = fmt.Errorf("no replay file of recorded %q mock", host)
+			log.Print(errNoReplay)
+			continue
+		}
+		out, err := yaml.Marshal(stubs)
+		if err != nil {
+			return err
+		}
+		if secrets != nil {
+			out = []byte(secrets.Mask(string(out)))
+		}
+		err = ioutil.WriteFile(path, out, os.FileMode(0644))
+		if err != nil {
+			log.Printf("Error on write records of %q mock: %v", host, err)
+			return err
+		}
+		log.Printf("Recorded %d stubs of %q mock: %s", len(stubs), host, path)
+	}
+	return errNoReplay
+}
+
+// replayPath returns the replay file of the first config of the host which proxies requests
+func replayPath(host string, confs []config.MockConfigs) string {
+	for _, conf := range confs {
+		if c, ok := conf[host]; ok && c.Upstream != "" && c.Replay != "" {
+			return c.Replay
+		}
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package mock
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vkd/microtest/config"
+)
+
+func TestRecorder_Record(t *testing.T) {
+	r := newRecorder(config.RedactConfig{
+		Headers: []string{"x-token"},
+		Fields:  []string{"password"},
+	})
+
+	res := &mockResponse{
+		Status: http.StatusCreated,
+		Header: http.Header{"X-Token": []string{"secret"}, "Content-Type": []string{"application/json"}},
+		Body:   []byte(`{"users": [{"name": "mike", "password": "123"}]}`),
+	}
+	r.Record("users", "POST", "/users?role=admin", res)
+	r.Record("users", "POST", "/users?role=admin", &mockResponse{Status: http.StatusConflict})
+
+	want := []config.MockConfig{{
+		Method:  "POST",
+		URL:     "/users?role=admin",
+		Status:  http.StatusCreated,
+		Headers: map[string]string{"X-Token": redacted, "Content-Type": "application/json"},
+		Out:     `{"users":[{"name":"mike","password":"REDACTED"}]}`,
+	}}
+	if got := r.stubs["users"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("recorder.stubs = %+v, want %+v", got, want)
+	}
+}
+
+func TestRecorder_Write(t *testing.T) {
+	dir := t.TempDir()
+	r := newRecorder(config.RedactConfig{})
+	r.Record("users", "GET", "/users/1", &mockResponse{Status: http.StatusOK, Body: []byte(`{"id": 1}`)})
+
+	global := config.MockConfigs{"users": {Stubs: []config.MockConfig{{URL: "/users"}}}}
+	test := config.MockConfigs{"users": {Upstream: "http://users", Replay: filepath.Join(dir, "users.recorded.yaml")}}
+
+	if err := r.Write(nil, global); err == nil {
+		t.Errorf("recorder.Write() without replay error = nil")
+	}
+	if err := r.Write(nil, global, test); err != nil {
+		t.Fatalf("recorder.Write() error = %v", err)
+	}
+	bs, err := ioutil.ReadFile(filepath.Join(dir, "users.recorded.yaml"))
+	if err != nil || !strings.Contains(string(bs), "/users/1") {
+		t.Errorf("replay file = %s, error = %v", bs, err)
+	}
+}
@@ -0,0 +1,209 @@
+package mock
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"github.com/vkd/microtest/config"
+)
+
+// smtpMock captures sent emails as messages:
+// {"from": "", "to": [], "subject": "", "headers": {}, "body": ""}
+type smtpMock struct {
+	tcpServer
+
+	conf *config.ProtocolMockConfig
+}
+
+func newSMTPMock(name string, conf *config.ProtocolMockConfig) (Protocol, error) {
+	return &smtpMock{tcpServer: tcpServer{name: name}, conf: conf}, nil
+}
+
+func (m *smtpMock) Listen(port int) error {
+	return m.listen(port, m.serve)
+}
+
+func (m *smtpMock) serve(conn net.Conn) {
+	tp := textproto.NewConn(conn)
+
+	var from string
+	var to []string
+	authorized := m.conf.Username == "" && m.conf.Password == ""
+
+	reply := func(code int, msg string) bool {
+		return tp.PrintfLine("%d %s", code, msg) == nil
+	}
+
+	if !reply(220, "microtest ESMTP") {
+		return
+	}
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg := line, ""
+		if i := strings.Index(line, " "); i > 0 {
+			cmd, arg = line[:i], line[i+1:]
+		}
+
+		ok := true
+		switch strings.ToUpper(cmd) {
+		case "HELO":
+			ok = reply(250, "microtest")
+		case "EHLO":
+			ok = tp.PrintfLine("250-microtest") == nil && reply(250, "AUTH PLAIN LOGIN")
+		case "MAIL":
+			if !authorized {
+				ok = reply(530, "Authentication required")
+				break
+			}
+			from = smtpAddress(arg)
+			ok = reply(250, "OK")
+		case "RCPT":
+			to = append(to, smtpAddress(arg))
+			ok = reply(250, "OK")
+		case "DATA":
+			if !reply(354, "End data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			data, err := ioutil.ReadAll(tp.DotReader())
+			if err != nil {
+				return
+			}
+			m.addMessage(smtpMessage(from, to, data))
+			from, to = "", nil
+			ok = reply(250, "OK")
+		case "RSET":
+			from, to = "", nil
+			ok = reply(250, "OK")
+		case "NOOP":
+			ok = reply(250, "OK")
+		case "AUTH":
+			user, pass, err := m.readAuth(tp, arg)
+			if err != nil {
+				ok = reply(501, err.Error())
+				break
+			}
+			if m.conf.Username != "" || m.conf.Password != "" {
+				if user != m.conf.Username || pass != m.conf.Password {
+					ok = reply(535, "Authentication credentials invalid")
+					break
+				}
+			}
+			authorized = true
+			ok = reply(235, "Authentication successful")
+		case "QUIT":
+			reply(221, "Bye")
+			return
+		default:
+			ok = reply(502, "Command not implemented")
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// readAuth reads credentials of AUTH PLAIN (with an initial response or not) and AUTH LOGIN
+func (m *smtpMock) readAuth(tp *textproto.Conn, arg string) (user, pass string, err error) {
+	mechanism, initial := arg, ""
+	if i := strings.Index(arg, " "); i > 0 {
+		mechanism, initial = arg[:i], arg[i+1:]
+	}
+
+	// challenge returns the initial response or reads a response to the challenge
+	challenge := func(prompt string) (string, error) {
+		if initial != "" {
+			resp := initial
+			initial = ""
+			return smtpDecode(resp)
+		}
+		err := tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(prompt)))
+		if err != nil {
+			return "", err
+		}
+		line, err := tp.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		return smtpDecode(line)
+	}
+
+	switch strings.ToUpper(mechanism) {
+	case "PLAIN":
+		resp, err := challenge("")
+		if err != nil {
+			return "", "", err
+		}
+		// authorization identity, user and password are separated by zero bytes
+		parts := strings.Split(resp, "\x00")
+		if len(parts) != 3 {
+			return "", "", fmt.Errorf("wrong PLAIN credentials")
+		}
+		return parts[1], parts[2], nil
+	case "LOGIN":
+		user, err := challenge("Username:")
+		if err != nil {
+			return "", "", err
+		}
+		pass, err := challenge("Password:")
+		if err != nil {
+			return "", "", err
+		}
+		return user, pass, nil
+	}
+	return "", "", fmt.Errorf("unknown AUTH mechanism: %q", mechanism)
+}
+
+func smtpDecode(s string) (string, error) {
+	bs, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", fmt.Errorf("wrong base64 of AUTH: %v", err)
+	}
+	return string(bs), nil
+}
+
+// smtpAddress trims "FROM:<a@b.c>" to "a@b.c"
+func smtpAddress(arg string) string {
+	if i := strings.Index(arg, ":"); i >= 0 {
+		arg = arg[i+1:]
+	}
+	if i := strings.Index(arg, " "); i >= 0 {
+		arg = arg[:i]
+	}
+	return strings.Trim(arg, "<>")
+}
+
+func smtpMessage(from string, to []string, data []byte) map[string]interface{} {
+	out := map[string]interface{}{
+		"from": from,
+		"to":   to,
+	}
+
+	msg, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(string(data))))
+	if err != nil {
+		out["body"] = string(data)
+		return out
+	}
+
+	headers := map[string]string{}
+	for k := range msg.Header {
+		headers[k] = msg.Header.Get(k)
+	}
+	body, err := ioutil.ReadAll(msg.Body)
+	if err != nil {
+		body = []byte(fmt.Sprintf("error on read body: %v", err))
+	}
+
+	out["subject"] = msg.Header.Get("Subject")
+	out["headers"] = headers
+	out["body"] = string(body)
+	return out
+}
@@ -1,4 +1,5 @@
-package main
+// Package prettylog prints headers of log output
+package prettylog
 
 import (
 	"fmt"
@@ -13,21 +14,24 @@ const (
 	h1 = 4
 )
 
-func LogPrintfH1(format string, args ...interface{}) {
+// H1 prints the header of the first level
+func H1(format string, args ...interface{}) {
 	str := fmt.Sprintf(format, args...)
 	log.Print(strings.Repeat(h1Delim, len(str)+h1))
 	log.Printf("| %s |", str)
 	log.Print(strings.Repeat(h1Delim, len(str)+h1))
 }
 
-func LogPrintfH2(format string, args ...interface{}) {
+// H2 prints the header of the second level
+func H2(format string, args ...interface{}) {
 	str := fmt.Sprintf(format, args...)
 	log.Print(strings.Repeat(h2Delim, len(str)))
 	log.Printf("%s", str)
 	log.Print(strings.Repeat(h2Delim, len(str)))
 }
 
-func LogPrintH2Borders(format string, args ...interface{}) func() {
+// H2Borders prints the header of the second level and returns a func printing its bottom border
+func H2Borders(format string, args ...interface{}) func() {
 	str := fmt.Sprintf(format, args...)
 	log.Print()
 	log.Print(strings.Repeat(h2Delim, len(str)))
@@ -0,0 +1,100 @@
+package protos
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Files are descriptors of services and messages
+type Files struct {
+	files *protoregistry.Files
+}
+
+// Load reads a .proto file (imports are resolved from its directory)
+// or a descriptor set built by `protoc --include_imports --descriptor_set_out`
+func Load(path string) (*Files, error) {
+	if strings.HasSuffix(path, ".proto") {
+		return compile(path)
+	}
+
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set descriptorpb.FileDescriptorSet
+	err = proto.Unmarshal(bs, &set)
+	if err != nil {
+		return nil, fmt.Errorf("parse descriptor set (%s): %v", path, err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, err
+	}
+	return &Files{files}, nil
+}
+
+func compile(path string) (*Files, error) {
+	c := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			ImportPaths: []string{filepath.Dir(path)},
+		}),
+	}
+	compiled, err := c.Compile(context.Background(), filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+
+	files := &protoregistry.Files{}
+	var register func(fd protoreflect.FileDescriptor) error
+	register = func(fd protoreflect.FileDescriptor) error {
+		if _, err := files.FindFileByPath(fd.Path()); err == nil {
+			return nil
+		}
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			if err := register(imports.Get(i).FileDescriptor); err != nil {
+				return err
+			}
+		}
+		return files.RegisterFile(fd)
+	}
+	for _, fd := range compiled {
+		if err := register(fd); err != nil {
+			return nil, err
+		}
+	}
+	return &Files{files}, nil
+}
+
+// FindMethod finds a method by its full name: "pkg.Service/Method" or "/pkg.Service/Method"
+func (f *Files) FindMethod(name string) (protoreflect.MethodDescriptor, error) {
+	name = strings.TrimPrefix(name, "/")
+	i := strings.LastIndex(name, "/")
+	if i < 0 {
+		return nil, fmt.Errorf("wrong grpc method name: %q (expect: pkg.Service/Method)", name)
+	}
+
+	d, err := f.files.FindDescriptorByName(protoreflect.FullName(name[:i]))
+	if err != nil {
+		return nil, fmt.Errorf("service %q not found: %v", name[:i], err)
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a service", name[:i])
+	}
+	md := sd.Methods().ByName(protoreflect.Name(name[i+1:]))
+	if md == nil {
+		return nil, fmt.Errorf("method %q not found", name)
+	}
+	return md, nil
+}
@@ -0,0 +1,34 @@
+package protos
+
+import "testing"
+
+func TestFiles_FindMethod(t *testing.T) {
+	files, err := Load("testdata/users.proto")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		wantErr bool
+	}{
+		{"full name", "users.v1.Users/GetUser", false},
+		{"leading slash", "/users.v1.Users/GetUser", false},
+		{"unknown method", "users.v1.Users/DeleteUser", true},
+		{"unknown service", "users.v1.Groups/GetUser", true},
+		{"message", "users.v1.User/GetUser", true},
+		{"without method", "users.v1.Users", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md, err := files.FindMethod(tt.method)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FindMethod() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && md.Output().FullName() != "users.v1.User" {
+				t.Errorf("FindMethod() output = %s", md.Output().FullName())
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package retry
+
+import (
+	"context"
+	"time"
+
+	"github.com/vkd/microtest/duration"
+)
+
+const DefaultInterval = 200 * time.Millisecond
+
+// Config repeats a check until it is passed or the timeout is over
+type Config struct {
+	Timeout duration.StringDuration `yaml:"timeout"`
+	// Interval between attempts (default: 200ms)
+	Interval duration.StringDuration `yaml:"interval"`
+}
+
+// Do calls f until it returns nil or the timeout is over and returns the last error,
+// f is called once when c is nil
+func Do(c *Config, f func() error) error {
+	return DoContext(context.Background(), c, f)
+}
+
+// DoContext is Do stopped by ctx, the error of ctx is returned when it is done between attempts
+func DoContext(ctx context.Context, c *Config, f func() error) error {
+	if c == nil {
+		return f()
+	}
+	interval := time.Duration(c.Interval)
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	deadline := time.Now().Add(time.Duration(c.Timeout))
+	for {
+		err := f()
+		if err == nil || time.Now().Add(interval).After(deadline) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package retry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vkd/microtest/duration"
+)
+
+func TestDo(t *testing.T) {
+	errNotReady := errors.New("not ready")
+
+	tests := []struct {
+		name      string
+		conf      *Config
+		okAfter   int
+		wantCalls int
+		wantErr   error
+	}{
+		{"without config", nil, 2, 1, errNotReady},
+		{"passed", &Config{Timeout: duration.StringDuration(time.Second), Interval: duration.StringDuration(time.Millisecond)}, 3, 3, nil},
+		{"timeout", &Config{Timeout: duration.StringDuration(30 * time.Millisecond), Interval: duration.StringDuration(10 * time.Millisecond)}, 100, 0, errNotReady},
+		{"first attempt", &Config{}, 1, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := Do(tt.conf, func() error {
+				calls++
+				if calls < tt.okAfter {
+					return errNotReady
+				}
+				return nil
+			})
+			if err != tt.wantErr {
+				t.Errorf("Do() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantCalls == 0 && calls < 2 {
+				t.Errorf("Do() calls = %d, want retries", calls)
+			}
+			if tt.wantCalls != 0 && calls != tt.wantCalls {
+				t.Errorf("Do() calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+var (
+	marshalOptions   = protojson.MarshalOptions{UseProtoNames: true}
+	unmarshalOptions = protojson.UnmarshalOptions{}
+)
+
+type Result struct {
+	Code    codes.Code
+	Message string
+	Body    []byte
+	Header  metadata.MD
+}
+
+// Invoke calls an unary method with a json encoded request
+func Invoke(addr string, md protoreflect.MethodDescriptor, body []byte, header map[string]string, timeout time.Duration) (*Result, error) {
+	return InvokeContext(context.Background(), addr, md, body, header, timeout)
+}
+
+// InvokeContext calls the method until ctx is done or the timeout is expired
+func InvokeContext(ctx context.Context, addr string, md protoreflect.MethodDescriptor, body []byte, header map[string]string, timeout time.Duration) (*Result, error) {
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	in, err := decode(md.Input(), body)
+	if err != nil {
+		return nil, err
+	}
+	out := dynamicpb.NewMessage(md.Output())
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ctx = metadata.NewOutgoingContext(ctx, metadata.New(header))
+
+	res := &Result{}
+	err = conn.Invoke(ctx, MethodName(md), in, out, grpc.Header(&res.Header))
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil, err
+	}
+	res.Code, res.Message = st.Code(), st.Message()
+	if err != nil {
+		return res, nil
+	}
+
+	res.Body, err = marshalOptions.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// MethodName returns "/pkg.Service/Method"
+func MethodName(md protoreflect.MethodDescriptor) string {
+	return "/" + string(md.Parent().FullName()) + "/" + string(md.Name())
+}
+
+func decode(d protoreflect.MessageDescriptor, body []byte) (*dynamicpb.Message, error) {
+	msg := dynamicpb.NewMessage(d)
+	if len(body) == 0 {
+		return msg, nil
+	}
+	err := unmarshalOptions.Unmarshal(body, msg)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// ParseCode parses names like "NOT_FOUND" or "NotFound"
+func ParseCode(name string) (codes.Code, bool) {
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if c.String() == name || CodeName(c) == name {
+			return c, true
+		}
+	}
+	return codes.Unknown, false
+}
+
+// codeNames are names of codes in proto style
+var codeNames = map[codes.Code]string{
+	codes.OK:                 "OK",
+	codes.Canceled:           "CANCELLED",
+	codes.Unknown:            "UNKNOWN",
+	codes.InvalidArgument:    "INVALID_ARGUMENT",
+	codes.DeadlineExceeded:   "DEADLINE_EXCEEDED",
+	codes.NotFound:           "NOT_FOUND",
+	codes.AlreadyExists:      "ALREADY_EXISTS",
+	codes.PermissionDenied:   "PERMISSION_DENIED",
+	codes.ResourceExhausted:  "RESOURCE_EXHAUSTED",
+	codes.FailedPrecondition: "FAILED_PRECONDITION",
+	codes.Aborted:            "ABORTED",
+	codes.OutOfRange:         "OUT_OF_RANGE",
+	codes.Unimplemented:      "UNIMPLEMENTED",
+	codes.Internal:           "INTERNAL",
+	codes.Unavailable:        "UNAVAILABLE",
+	codes.DataLoss:           "DATA_LOSS",
+	codes.Unauthenticated:    "UNAUTHENTICATED",
+}
+
+// CodeName returns the name of code in proto style: "NOT_FOUND"
+func CodeName(c codes.Code) string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return c.String()
+}
@@ -0,0 +1,52 @@
+package rpc
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestParseCode(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   codes.Code
+		wantOk bool
+	}{
+		{"OK", codes.OK, true},
+		{"NOT_FOUND", codes.NotFound, true},
+		{"NotFound", codes.NotFound, true},
+		{"DEADLINE_EXCEEDED", codes.DeadlineExceeded, true},
+		{"UNAUTHENTICATED", codes.Unauthenticated, true},
+		{"CANCELLED", codes.Canceled, true},
+		{"not_found", codes.Unknown, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseCode(tt.name)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("ParseCode() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestCodeName(t *testing.T) {
+	tests := []struct {
+		code codes.Code
+		want string
+	}{
+		{codes.OK, "OK"},
+		{codes.Canceled, "CANCELLED"},
+		{codes.NotFound, "NOT_FOUND"},
+		{codes.DeadlineExceeded, "DEADLINE_EXCEEDED"},
+		{codes.Unauthenticated, "UNAUTHENTICATED"},
+		{codes.Code(20), "Code(20)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := CodeName(tt.code); got != tt.want {
+				t.Errorf("CodeName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package rpc
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Handler answers an unary call with a json encoded response or an error status
+type Handler func(method string, body []byte) (out []byte, code codes.Code, msg string)
+
+type MethodFinder interface {
+	FindMethod(name string) (protoreflect.MethodDescriptor, error)
+}
+
+// Server serves unary methods of any services described by the finder
+type Server struct {
+	srv     *grpc.Server
+	methods MethodFinder
+	handler Handler
+
+	wg sync.WaitGroup
+}
+
+func NewServer(methods MethodFinder, h Handler) *Server {
+	s := &Server{
+		methods: methods,
+		handler: h,
+	}
+	s.srv = grpc.NewServer(grpc.UnknownServiceHandler(s.handle))
+	return s
+}
+
+func (s *Server) Serve(ln net.Listener) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		_ = s.srv.Serve(ln)
+	}()
+}
+
+func (s *Server) Stop() {
+	s.srv.Stop()
+	s.wg.Wait()
+}
+
+func (s *Server) handle(_ interface{}, stream grpc.ServerStream) error {
+	method, ok := grpc.MethodFromServerStream(stream)
+	if !ok {
+		return status.Error(codes.Internal, "method not found in stream")
+	}
+	md, err := s.methods.FindMethod(method)
+	if err != nil {
+		return status.Error(codes.Unimplemented, err.Error())
+	}
+
+	in := dynamicpb.NewMessage(md.Input())
+	err = stream.RecvMsg(in)
+	if err != nil {
+		return err
+	}
+	body, err := marshalOptions.Marshal(in)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	outBody, code, msg := s.handler(method, body)
+	if code != codes.OK {
+		return status.Error(code, msg)
+	}
+
+	out, err := decode(md.Output(), outBody)
+	if err != nil {
+		return status.Error(codes.Internal, fmt.Sprintf("MICROTEST: wrong response of %s: %v", method, err))
+	}
+	return stream.SendMsg(out)
+}
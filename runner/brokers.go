@@ -0,0 +1,173 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/vkd/microtest/broker"
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/expect"
+	"github.com/vkd/microtest/prettylog"
+)
+
+type Brokers struct {
+	drivers map[string]broker.Driver
+}
+
+// ConnectBrokers connects to brokers, hosts are ips of started services by their names
+func ConnectBrokers(conf map[string]config.BrokerConfig, hosts map[string]string) (*Brokers, error) {
+	b := &Brokers{drivers: map[string]broker.Driver{}}
+	for name, c := range conf {
+		u := resolveServiceHosts(c.URL, hosts)
+		d, err := broker.Open(c.Driver, u)
+		if err != nil {
+			b.Close()
+			return nil, fmt.Errorf("connect to %q broker (%s): %v", name, u, err)
+		}
+		b.drivers[name] = d
+	}
+	return b, nil
+}
+
+// resolveServiceHosts replaces names of services in url by their ips,
+// url is "scheme://host:port/..." or "host:port[,host:port]"
+func resolveServiceHosts(rawurl string, hosts map[string]string) string {
+	resolve := func(hostport string) string {
+		host, port, err := net.SplitHostPort(hostport)
+		if err != nil {
+			host, port = hostport, ""
+		}
+		if ip, ok := hosts[host]; ok {
+			host = ip
+		}
+		if port == "" {
+			return host
+		}
+		return net.JoinHostPort(host, port)
+	}
+
+	if strings.Contains(rawurl, "://") {
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			return rawurl
+		}
+		u.Host = resolve(u.Host)
+		return u.String()
+	}
+
+	addrs := strings.Split(rawurl, ",")
+	for i, a := range addrs {
+		addrs[i] = resolve(a)
+	}
+	return strings.Join(addrs, ",")
+}
+
+func (b *Brokers) driver(name string) (broker.Driver, error) {
+	if b == nil || b.drivers[name] == nil {
+		return nil, fmt.Errorf("broker not found: %q", name)
+	}
+	return b.drivers[name], nil
+}
+
+func (b *Brokers) Publish(p *config.PublishConfig) error {
+	d, err := b.driver(p.Broker)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = d.Publish(ctx, p.Topic, &broker.Message{
+		Key:     p.Key,
+		Headers: p.Headers,
+		Body:    []byte(p.Body),
+	})
+	if err != nil {
+		return fmt.Errorf("publish to %q broker (%s): %v", p.Broker, p.Topic, err)
+	}
+	return nil
+}
+
+// messageExpect is a subscription waiting for an expected message
+type messageExpect struct {
+	conf *config.MessageExpectConfig
+	sub  broker.Subscription
+}
+
+// Subscribe starts receiving of expected messages, it is called before the request
+func (b *Brokers) Subscribe(exps []config.MessageExpectConfig) ([]*messageExpect, error) {
+	var out []*messageExpect
+	for i := range exps {
+		e := &exps[i]
+		d, err := b.driver(e.Broker)
+		if err != nil {
+			closeMessageExpects(out)
+			return nil, err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		sub, err := d.Subscribe(ctx, e.Topic)
+		cancel()
+		if err != nil {
+			closeMessageExpects(out)
+			return nil, fmt.Errorf("subscribe to %q broker (%s): %v", e.Broker, e.Topic, err)
+		}
+		out = append(out, &messageExpect{conf: e, sub: sub})
+	}
+	return out, nil
+}
+
+// Wait waits for a message matched by the expect
+func (e *messageExpect) Wait() error {
+	timeout := time.Duration(e.conf.Timeout)
+	if timeout == 0 {
+		timeout = 3 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var received []*broker.Message
+	for {
+		msg, err := e.sub.Next(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				return fmt.Errorf("receive message of %q broker (%s): %v", e.conf.Broker, e.conf.Topic, err)
+			}
+			prettylog.H2("Expected message of %q broker (%s) not received in %s", e.conf.Broker, e.conf.Topic, timeout)
+			for i, m := range received {
+				log.Printf("Received message #%d: %s", i+1, string(m.Body))
+			}
+			return fmt.Errorf("message of %q broker (%s) not received", e.conf.Broker, e.conf.Topic)
+		}
+		received = append(received, msg)
+
+		if e.conf.Key != "" && e.conf.Key != msg.Key {
+			continue
+		}
+		if expect.CheckBody(msg.Body, &e.conf.ExpectConfig) == nil {
+			return nil
+		}
+	}
+}
+
+func closeMessageExpects(exps []*messageExpect) {
+	for _, e := range exps {
+		e.sub.Close()
+	}
+}
+
+func (b *Brokers) Close() {
+	if b == nil {
+		return
+	}
+	for name, d := range b.drivers {
+		err := d.Close()
+		if err != nil {
+			log.Printf("Error on close %q broker: %v", name, err)
+		}
+	}
+}
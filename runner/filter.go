@@ -0,0 +1,97 @@
+package runner
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/vkd/microtest/config"
+)
+
+// Filter selects tests by name (--run) and tags (--tags a,!slow)
+type Filter struct {
+	run     *regexp.Regexp
+	include []string
+	exclude []string
+}
+
+// NewFilter parses a regexp of test names and a list of tags, "!" excludes tags
+func NewFilter(run, tags string) (*Filter, error) {
+	f := &Filter{}
+	if run != "" {
+		re, err := regexp.Compile(run)
+		if err != nil {
+			return nil, fmt.Errorf("wrong --run regexp: %v", err)
+		}
+		f.run = re
+	}
+	for _, tag := range strings.Split(tags, ",") {
+		tag = strings.TrimSpace(tag)
+		switch {
+		case tag == "":
+		case strings.HasPrefix(tag, "!"):
+			f.exclude = append(f.exclude, tag[1:])
+		default:
+			f.include = append(f.include, tag)
+		}
+	}
+	return f, nil
+}
+
+// SkipReason returns why the test is skipped, empty for selected tests
+func (f *Filter) SkipReason(conf *config.Config, t *config.TestConfig) string {
+	if t.Skip != "" {
+		return t.Skip
+	}
+	if hasOnlyTests(conf) && !t.Only {
+		return "not only"
+	}
+	if f == nil {
+		return ""
+	}
+	if f.run != nil && !f.run.MatchString(t.Name) {
+		return "not matched by --run"
+	}
+
+	tags := map[string]bool{}
+	for _, tag := range conf.Tags {
+		tags[tag] = true
+	}
+	for _, tag := range t.Tags {
+		tags[tag] = true
+	}
+	for _, tag := range f.exclude {
+		if tags[tag] {
+			return "excluded by tag: " + tag
+		}
+	}
+	if len(f.include) == 0 {
+		return ""
+	}
+	for _, tag := range f.include {
+		if tags[tag] {
+			return ""
+		}
+	}
+	return "not matched by --tags"
+}
+
+// Selected returns count of tests which are run
+func (f *Filter) Selected(conf *config.Config) int {
+	n := 0
+	for i := range conf.Tests {
+		if f.SkipReason(conf, &conf.Tests[i]) == "" {
+			n++
+		}
+	}
+	return n
+}
+
+func hasOnlyTests(conf *config.Config) bool {
+	for _, t := range conf.Tests {
+		if t.Only {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/vkd/microtest/config"
+)
+
+func TestFilter_SkipReason(t *testing.T) {
+	conf := &config.Config{
+		Tags: []string{"users"},
+		Tests: []config.TestConfig{
+			{Name: "create user", Tags: []string{"smoke"}},
+			{Name: "delete user", Tags: []string{"slow"}},
+			{Name: "import users", Skip: "broken upstream"},
+		},
+	}
+
+	tests := []struct {
+		name string
+		run  string
+		tags string
+		want []string
+	}{
+		{"without filter", "", "", []string{"", "", "broken upstream"}},
+		{"run", "^create", "", []string{"", "not matched by --run", "broken upstream"}},
+		{"include tag", "", "smoke", []string{"", "not matched by --tags", "broken upstream"}},
+		{"include tag of file", "", "users", []string{"", "", "broken upstream"}},
+		{"exclude tag", "", "users,!slow", []string{"", "excluded by tag: slow", "broken upstream"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFilter(tt.run, tt.tags)
+			if err != nil {
+				t.Fatalf("NewFilter() error = %v", err)
+			}
+			for i := range conf.Tests {
+				if got := f.SkipReason(conf, &conf.Tests[i]); got != tt.want[i] {
+					t.Errorf("Filter.SkipReason(%q) = %q, want %q", conf.Tests[i].Name, got, tt.want[i])
+				}
+			}
+		})
+	}
+
+	only := &config.Config{Tests: []config.TestConfig{{Name: "a"}, {Name: "b", Only: true}}}
+	var f *Filter
+	if got := f.SkipReason(only, &only.Tests[0]); got != "not only" {
+		t.Errorf("Filter.SkipReason() = %q, want %q", got, "not only")
+	}
+	if got := f.Selected(only); got != 1 {
+		t.Errorf("Filter.Selected() = %d, want 1", got)
+	}
+
+	if _, err := NewFilter("(", ""); err == nil {
+		t.Errorf("NewFilter() expected error on wrong regexp")
+	}
+}
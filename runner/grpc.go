@@ -0,0 +1,50 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/expect"
+	"github.com/vkd/microtest/protos"
+	"github.com/vkd/microtest/rpc"
+)
+
+// sendGRPCRequest calls an unary method, request and response bodies are json encoded messages
+func sendGRPCRequest(ctx context.Context, files *protos.Files, ip string, port int, conf *config.RequestConfig) (*expect.Result, error) {
+	if files == nil {
+		return nil, fmt.Errorf("'proto' not found in config")
+	}
+	md, err := files.FindMethod(conf.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := time.Duration(conf.Timeout)
+	if timeout == 0 {
+		timeout = 3 * time.Second
+	}
+
+	res, err := rpc.InvokeContext(ctx, fmt.Sprintf("%s:%d", ip, port), md, []byte(conf.Body), conf.Headers, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	for k, vs := range res.Header {
+		for _, v := range vs {
+			header.Add(k, v)
+		}
+	}
+
+	return &expect.Result{
+		Method:      config.ProtocolGRPC,
+		URL:         rpc.MethodName(md),
+		GRPCStatus:  rpc.CodeName(res.Code),
+		GRPCMessage: res.Message,
+		Header:      header,
+		RawBody:     res.Body,
+	}, nil
+}
@@ -0,0 +1,106 @@
+package runner
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/expect"
+	"github.com/vkd/microtest/mock"
+	"github.com/vkd/microtest/protos"
+)
+
+// freePort returns a port free for listening
+func freePort(t *testing.T) int {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestGRPCMock(t *testing.T) {
+	pm, err := mock.NewProtocol("users", &config.ProtocolMockConfig{
+		Protocol: config.ProtocolGRPC,
+		Proto:    "../protos/testdata/users.proto",
+		Methods: map[string]config.GRPCMethodConfig{
+			"users.v1.Users/GetUser": {
+				Body: `{"user_id": "1", "name": "Mike", "status": "STATUS_ACTIVE", "created_at": "2020-01-02T03:04:05Z"}`,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("mock.NewProtocol() error = %v", err)
+	}
+	port := freePort(t)
+	if err = pm.Listen(port); err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer pm.Stop()
+
+	files, err := protos.Load("../protos/testdata/users.proto")
+	if err != nil {
+		t.Fatalf("protos.Load() error = %v", err)
+	}
+	res, err := sendGRPCRequest(context.Background(), files, "127.0.0.1", port, &config.RequestConfig{
+		Protocol: config.ProtocolGRPC,
+		URL:      "users.v1.Users/GetUser",
+		Headers:  map[string]string{"x-trace-id": "1"},
+		Body:     `{"user_id": 1}`,
+	})
+	if err != nil {
+		t.Fatalf("sendGRPCRequest() error = %v", err)
+	}
+	err = expect.New().Check(res, &config.ExpectConfig{
+		Body: `{"user_id": "1", "name": "Mike", "status": "STATUS_ACTIVE", "created_at": "2020-01-02T03:04:05Z"}`,
+	})
+	if err != nil {
+		t.Errorf("Check() response error = %v", err)
+	}
+
+	msgs := pm.Messages()
+	if len(msgs) != 1 || msgs[0].URL != "/users.v1.Users/GetUser" {
+		t.Fatalf("messages = %v", msgs)
+	}
+	if err = expect.New().Check(msgs[0], &config.ExpectConfig{Body: `{"user_id": "1"}`}); err != nil {
+		t.Errorf("Check() message error = %v", err)
+	}
+	if err = expect.New().Check(res, &config.ExpectConfig{GRPCStatus: "NOT_FOUND"}); err != expect.ErrWrongGRPCStatus {
+		t.Errorf("Check() error = %v, want %v", err, expect.ErrWrongGRPCStatus)
+	}
+}
+
+func TestGRPCMock_Status(t *testing.T) {
+	pm, err := mock.NewProtocol("users", &config.ProtocolMockConfig{
+		Protocol: config.ProtocolGRPC,
+		Proto:    "../protos/testdata/users.proto",
+		Methods: map[string]config.GRPCMethodConfig{
+			"users.v1.Users/GetUser": {Status: "NOT_FOUND", Message: "user not found"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("mock.NewProtocol() error = %v", err)
+	}
+	port := freePort(t)
+	if err = pm.Listen(port); err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer pm.Stop()
+
+	files, err := protos.Load("../protos/testdata/users.proto")
+	if err != nil {
+		t.Fatalf("protos.Load() error = %v", err)
+	}
+	res, err := sendGRPCRequest(context.Background(), files, "127.0.0.1", port, &config.RequestConfig{URL: "/users.v1.Users/GetUser"})
+	if err != nil {
+		t.Fatalf("sendGRPCRequest() error = %v", err)
+	}
+	if res.GRPCStatus != "NOT_FOUND" || res.GRPCMessage != "user not found" {
+		t.Errorf("sendGRPCRequest() status = %s (%s)", res.GRPCStatus, res.GRPCMessage)
+	}
+	if err = expect.New().Check(res, &config.ExpectConfig{GRPCStatus: "NotFound"}); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+}
@@ -1,20 +1,24 @@
-package main
+package runner
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/expect"
 )
 
-func sendRequest(ip string, port int, conf *RequestConfig) (*requestResult, error) {
+func sendRequest(ctx context.Context, ip string, port int, conf *config.RequestConfig) (*expect.Result, error) {
 	method := conf.Method
 	if method == "" {
 		method = "GET"
 	}
 
-	req, err := http.NewRequest(method, fmt.Sprintf("http://%s:%d/%s", ip, port, strings.TrimLeft(conf.URL, "/")), strings.NewReader(conf.Body))
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("http://%s:%d/%s", ip, port, strings.TrimLeft(conf.URL, "/")), strings.NewReader(conf.Body))
 	if err != nil {
 		return nil, err
 	}
@@ -42,15 +46,11 @@ func sendRequest(ip string, port int, conf *RequestConfig) (*requestResult, erro
 		return nil, err
 	}
 
-	return &requestResult{
+	return &expect.Result{
+		Method:  method,
 		URL:     "/" + strings.TrimLeft(conf.URL, "/"),
 		Status:  resp.StatusCode,
+		Header:  resp.Header,
 		RawBody: body,
 	}, nil
 }
-
-type requestResult struct {
-	URL     string
-	Status  int
-	RawBody []byte
-}
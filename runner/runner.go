@@ -0,0 +1,488 @@
+// Package runner runs tests of configs: it starts mocks, services and the tested service in docker,
+// sends requests of tests and checks their expects
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/mock"
+	"github.com/vkd/microtest/prettylog"
+	"github.com/vkd/microtest/retry"
+	"github.com/vkd/microtest/vars"
+)
+
+const (
+	EnvHostWorkdir = "MICROTEST_HOST_WORKDIR"
+)
+
+// Options of a run
+type Options struct {
+	// Docker is a client of docker, a client by environment variables is used by default
+	Docker *docker.Client
+	// IP is an address of mocks available from containers, the gateway of docker bridge network by default
+	IP string
+	// Workdir is a directory of tests mounted to the tested service, MICROTEST_HOST_WORKDIR by default
+	Workdir string
+	// Filter selects tests, nil runs all tests except skipped ones
+	Filter *Filter
+	// Record proxies requests of mock hosts with upstream and writes them to replay files
+	Record bool
+	// Debug enables verbose logs of runs and mocks
+	Debug bool
+	// Secrets are masked in replay files written by Record
+	Secrets *config.SecretMask
+}
+
+// Runner runs tests of a config, mocks, services and the tested service are shared by the tests
+type Runner struct {
+	Conf *config.Config
+
+	opts Options
+	dc   *docker.Client
+
+	mocks         *mock.Mocks
+	testedService *TestedService
+	brokers       *Brokers
+	services      map[string]*Service
+	started       []*Service
+
+	vars   vars.Map
+	failed bool
+}
+
+// Result is a result of tests of a config
+type Result struct {
+	Name  string
+	Tests []*TestResult
+}
+
+// TestResult is a result of a test, Skipped is a reason of skipped test
+type TestResult struct {
+	Name     string
+	Skipped  string
+	Err      error
+	Duration time.Duration
+}
+
+// Passed returns count of passed tests
+func (r *Result) Passed() int {
+	n := 0
+	for _, t := range r.Tests {
+		if t.Skipped == "" && t.Err == nil {
+			n++
+		}
+	}
+	return n
+}
+
+func New(conf *config.Config, opts Options) *Runner {
+	return &Runner{
+		Conf:     conf,
+		opts:     opts,
+		services: map[string]*Service{},
+		vars:     vars.Map{},
+	}
+}
+
+// Mocks returns mocks of the run, they are started by Start
+func (m *Runner) Mocks() *mock.Mocks {
+	return m.mocks
+}
+
+// Run starts the config, runs selected tests until the first failure or done ctx and stops the config
+func (m *Runner) Run(ctx context.Context) (*Result, error) {
+	res := &Result{Name: m.Conf.Name}
+
+	err := m.Start(ctx)
+	if err != nil {
+		return res, err
+	}
+	defer m.Stop()
+
+	return res, m.runTests(ctx, res)
+}
+
+func (m *Runner) runTests(ctx context.Context, res *Result) error {
+	defer func() {
+		printSummary(res)
+	}()
+
+	for _, t := range m.Conf.Tests {
+		if err := ctx.Err(); err != nil {
+			m.failed = true
+			return err
+		}
+		if reason := m.opts.Filter.SkipReason(m.Conf, &t); reason != "" {
+			log.Printf("Skip test: %s (%s)", t.Name, reason)
+			res.Tests = append(res.Tests, &TestResult{Name: t.Name, Skipped: reason})
+			continue
+		}
+
+		start := time.Now()
+		err := m.Test(ctx, &t)
+		res.Tests = append(res.Tests, &TestResult{Name: t.Name, Err: err, Duration: time.Since(start)})
+		if err != nil {
+			prettylog.H1("Error on test (%s): %v", t.Name, err)
+			return err
+		}
+	}
+
+	log.Print("\n")
+	prettylog.H1("All tests completed successful")
+
+	return nil
+}
+
+func printSummary(res *Result) {
+	var skipped []string
+	for _, t := range res.Tests {
+		if t.Skipped != "" {
+			skipped = append(skipped, fmt.Sprintf("%s: %s", t.Name, t.Skipped))
+		}
+	}
+	if len(skipped) == 0 {
+		return
+	}
+	bottom := prettylog.H2Borders("Passed: %d, skipped: %d", res.Passed(), len(skipped))
+	for _, s := range skipped {
+		log.Printf("- %s", s)
+	}
+	bottom()
+}
+
+// Start starts mocks, services and the tested service, then waits for the ping request,
+// everything started is stopped on errors
+func (m *Runner) Start(ctx context.Context) (err error) {
+	conf := m.Conf
+
+	defer func() {
+		if err != nil {
+			m.failed = true
+			m.Stop()
+		}
+	}()
+
+	m.dc = m.opts.Docker
+	if m.dc == nil {
+		m.dc, err = docker.NewClientFromEnv()
+		if err != nil {
+			return fmt.Errorf("docker client: %v", err)
+		}
+	}
+
+	workdir := m.opts.Workdir
+	if workdir == "" {
+		workdir = os.Getenv(EnvHostWorkdir)
+	}
+
+	ip := m.opts.IP
+	if ip == "" {
+		ip, err = bridgeGateway(m.dc)
+		if err != nil {
+			return err
+		}
+	}
+
+	prettylog.H2("Init test: %s", conf.Name)
+
+	err = conf.LoadReplays(m.opts.Record)
+	if err != nil {
+		return err
+	}
+
+	if m.opts.Debug {
+		log.Printf("Start mocks")
+	}
+	m.mocks = mock.NewMocks(conf.Mocks)
+	m.mocks.IsDebug = m.opts.Debug
+	m.mocks.Secrets = m.opts.Secrets
+	m.mocks.Record = m.opts.Record
+	m.mocks.SetRecordRedact(conf.Record.Redact)
+	err = m.mocks.LoadSpecs(conf.AllMocks()...)
+	if err != nil {
+		return err
+	}
+	m.mocks.ResetMocks(nil)
+	m.mocks.SetVars(m.vars)
+	err = m.mocks.Run()
+	if err != nil {
+		log.Printf("Error on run mocks")
+		return err
+	}
+
+	err = m.mocks.RunProtocolMocks(conf.ProtocolMocks)
+	if err != nil {
+		return err
+	}
+
+	var extraHosts []string
+	hosts := map[string]string{}
+
+	if m.opts.Debug && len(m.Conf.Services) > 0 {
+		log.Printf("Start services:")
+	}
+	for i := range m.Conf.Services {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		srv := NewService(&m.Conf.Services[i])
+		srv.IsDebug = m.opts.Debug
+		ip, err := srv.Start(m.dc)
+		if err != nil {
+			log.Printf("Error on start %q service: %v", srv.Name, err)
+			return err
+		}
+		m.started = append(m.started, srv)
+		m.services[srv.Name] = srv
+		extraHosts = append(extraHosts, fmt.Sprintf("%s: %s", srv.Name, ip))
+		m.mocks.SetHost(srv.Name, ip)
+		hosts[srv.Name] = ip
+	}
+
+	if m.opts.Debug {
+		for name, c := range conf.Brokers {
+			log.Printf("Connect to %q broker: %s", name, resolveServiceHosts(c.URL, hosts))
+		}
+	}
+	m.brokers, err = ConnectBrokers(conf.Brokers, hosts)
+	if err != nil {
+		return err
+	}
+
+	prettylog.H1("Start tests: %s", conf.Name)
+
+	m.testedService = NewTestedService(m.dc, conf, conf.Port)
+	m.testedService.IsDebug = m.opts.Debug
+	err = m.testedService.Run(&RunConfig{
+		MocksPort:  m.mocks.Port,
+		SelfIP:     ip,
+		Workdir:    workdir,
+		ExtraHosts: extraHosts,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = m.testedService.PingRequest(ctx, &conf.PingRequest)
+	if err != nil {
+		log.Printf("Error on ping request: %v", err)
+		return err
+	}
+	return nil
+}
+
+// Stop stops and removes the tested service (its logs are printed when a test is failed) and services,
+// then stops mocks and brokers
+func (m *Runner) Stop() {
+	conf := m.Conf
+
+	if m.testedService != nil {
+		errStop := m.testedService.Stop()
+		if errStop != nil {
+			log.Printf("Error on stop %q tested container: %v", conf.Image, errStop)
+		}
+		if m.failed {
+			m.testedService.PrintLogs()
+		}
+		errRem := m.testedService.Remove()
+		if errRem != nil {
+			log.Printf("Error on remove %q tested container: %v", conf.Image, errRem)
+		}
+		m.testedService = nil
+	}
+
+	for _, s := range m.started {
+		s.Stop(m.dc)
+	}
+	m.started = nil
+
+	if m.brokers != nil {
+		m.brokers.Close()
+		m.brokers = nil
+	}
+
+	if m.mocks != nil {
+		err := m.mocks.Stop()
+		if err != nil {
+			log.Printf("Error on stop mocks: %v", err)
+		}
+		if m.opts.Record {
+			err = m.mocks.WriteRecords(m.Conf.AllMocks()...)
+			if err != nil {
+				log.Printf("Error on write records: %v", err)
+			}
+		}
+		m.mocks = nil
+	}
+}
+
+// bridgeGateway returns the address of the host in docker bridge network
+func bridgeGateway(dc *docker.Client) (string, error) {
+	n, err := dc.NetworkInfo("bridge")
+	if err != nil {
+		return "", fmt.Errorf("inspect bridge network: %v", err)
+	}
+	for _, c := range n.IPAM.Config {
+		if c.Gateway != "" {
+			return c.Gateway, nil
+		}
+	}
+	return "", errors.New("gateway of bridge network not found")
+}
+
+// Test runs the test until it is completed or ctx is done, variables set by tests are available in next ones
+func (m *Runner) Test(ctx context.Context, t *config.TestConfig) error {
+	err := m.test(ctx, t, m.vars)
+	if err != nil {
+		m.failed = true
+	}
+	return err
+}
+
+func (m *Runner) test(ctx context.Context, t *config.TestConfig, vs vars.Map) (err error) {
+	if m.opts.Debug {
+		prettylog.H2("Start test: %s", t.Name)
+	} else {
+		log.Printf("Start test: %s", t.Name)
+	}
+
+	m.mocks.ResetMocks(t.Mocks)
+
+	defer func() {
+		errUnmatched := m.reportUnmatched()
+		if err == nil && m.Conf.FailOnUnmatched {
+			err = errUnmatched
+		}
+	}()
+
+	defer func() {
+		errAfter := m.runSteps(ctx, "after_each", m.Conf.AfterEach, t, vs)
+		if err == nil {
+			err = errAfter
+		}
+	}()
+
+	if t.Sleep > 0 {
+		if m.opts.Debug {
+			log.Printf("Sleep on %d sec", t.Sleep)
+		}
+		err = sleep(ctx, time.Duration(t.Sleep)*time.Second)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = m.runSteps(ctx, "before_each", m.Conf.BeforeEach, t, vs)
+	if err != nil {
+		return err
+	}
+	err = m.runSteps(ctx, "step", t.Steps, t, vs)
+	if err != nil {
+		return err
+	}
+
+	// a test with steps may have no own request
+	if len(t.Steps) > 0 && t.Request.URL == "" {
+		return nil
+	}
+	return m.request(ctx, &t.Request, &t.Expect, t.Publish, vs)
+}
+
+// sleep waits for d or done ctx
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// request publishes messages, sends the request and checks expects of it, mocks and brokers
+func (m *Runner) request(ctx context.Context, r *config.RequestConfig, exp *config.ExpectMockConfig, publish []config.PublishConfig, vs vars.Map) error {
+	// variables saved by previous requests are available in templates of mocks
+	m.mocks.SetVars(vs)
+	r.OverrideByVariables(vs)
+	exp.ExpectConfig.SetVars(vs)
+	for i := range exp.Messages {
+		exp.Messages[i].SetVars(vs)
+	}
+
+	// subscriptions are made before the request to receive messages published by it
+	messages, err := m.brokers.Subscribe(exp.Messages)
+	if err != nil {
+		return err
+	}
+	defer closeMessageExpects(messages)
+
+	for i := range publish {
+		err = m.brokers.Publish(&publish[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	rc := exp.Retry
+	if rc == nil && r.Expect != nil {
+		rc = r.Expect.Retry
+	}
+	// failures of mocks (unmatched requests in strict mode) cancel the request at once
+	reqCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := m.mocks.NotifyFailures(cancel)
+	defer stop()
+
+	// mocks are checked by requests of the last attempt only
+	var offsets map[string]int
+	err = retry.DoContext(reqCtx, rc, func() error {
+		offsets = m.mocks.Offsets()
+		return m.testedService.Request(reqCtx, r, &exp.ExpectConfig)
+	})
+
+	// failures of mocks explain wrong responses, so they are reported first
+	errMocks := m.mocks.CheckFailures()
+	if errMocks != nil {
+		return errMocks
+	}
+	if err != nil {
+		return err
+	}
+
+	err = m.mocks.CheckExpect(exp.Mocks, offsets)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range messages {
+		err = e.Wait()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reportUnmatched prints requests to mocks without matched stubs
+func (m *Runner) reportUnmatched() error {
+	unmatched := m.mocks.Unmatched()
+	if len(unmatched) == 0 {
+		return nil
+	}
+
+	bottom := prettylog.H2Borders("Unmatched mock requests: %d", len(unmatched))
+	for _, u := range unmatched {
+		log.Print(u.String())
+	}
+	bottom()
+
+	return fmt.Errorf("unmatched mock requests: %d", len(unmatched))
+}
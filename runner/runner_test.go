@@ -0,0 +1,151 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/duration"
+	"github.com/vkd/microtest/mock"
+	"github.com/vkd/microtest/retry"
+)
+
+// newChargeRunner returns a runner with the tested service calling the billing mock by handler
+func newChargeRunner(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, charge func(body string))) *Runner {
+	mocks := mock.NewMocks(config.MockConfigs{"billing": {Stubs: []config.MockConfig{{URL: "/charge", Out: `{}`}}}})
+	mocks.Port = 0
+	mocks.ResetMocks(nil)
+	if err := mocks.Run(); err != nil {
+		t.Fatalf("Mocks.Run() error = %v", err)
+	}
+	t.Cleanup(func() { mocks.Stop() })
+
+	charge := func(body string) {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("http://127.0.0.1:%d/charge", mocks.Port), strings.NewReader(body))
+		req.Host = "billing"
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("charge error = %v", err)
+			return
+		}
+		res.Body.Close()
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r, charge)
+	}))
+	t.Cleanup(srv.Close)
+
+	host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	m := New(&config.Config{}, Options{})
+	m.mocks = mocks
+	m.testedService = &TestedService{conf: m.Conf, ip: host}
+	m.testedService.port, _ = strconv.Atoi(port)
+	return m
+}
+
+func chargeStep(amount int) config.StepConfig {
+	body := fmt.Sprintf(`{"amount": %d}`, amount)
+	return config.StepConfig{
+		Request: &config.RequestConfig{Method: "POST", URL: "/charge", Body: body},
+		Expect:  config.ExpectMockConfig{Mocks: map[string]config.ExpectConfig{"billing": {BodyMin: body}}},
+	}
+}
+
+func TestRunner_runStepsMockExpects(t *testing.T) {
+	m := newChargeRunner(t, func(w http.ResponseWriter, r *http.Request, charge func(string)) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		charge(string(bs))
+	})
+
+	err := m.runSteps(context.Background(), "step", []config.StepConfig{chargeStep(10), chargeStep(20)}, &config.TestConfig{}, nil)
+	if err != nil {
+		t.Errorf("runSteps() error = %v", err)
+	}
+}
+
+func TestRunner_runTestsCancel(t *testing.T) {
+	sleep := []config.StepConfig{{Sleep: duration.StringDuration(time.Minute)}}
+	m := New(&config.Config{Tests: []config.TestConfig{
+		{Name: "first", Steps: sleep},
+		{Name: "second", Steps: sleep},
+	}}, Options{})
+	m.mocks = mock.NewMocks(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	res := &Result{}
+	start := time.Now()
+	err := m.runTests(ctx, res)
+	if err == nil || !strings.HasSuffix(err.Error(), context.Canceled.Error()) {
+		t.Errorf("runTests() error = %v, want %v", err, context.Canceled)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("runTests() is not stopped by ctx: %s", d)
+	}
+	if len(res.Tests) != 1 || res.Tests[0].Err == nil {
+		t.Errorf("runTests() results = %+v, want the failed first test", res.Tests)
+	}
+}
+
+func TestRunner_requestRetryMockExpects(t *testing.T) {
+	tests := []struct {
+		name    string
+		charges []string
+		wantErr bool
+	}{
+		{"last attempt", []string{`{"amount": 0}`, `{"amount": 10}`}, false},
+		{"failed attempt", []string{`{"amount": 10}`, `{"amount": 20}`}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			m := newChargeRunner(t, func(w http.ResponseWriter, r *http.Request, charge func(string)) {
+				charge(tt.charges[attempts])
+				attempts++
+				if attempts == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			})
+
+			s := chargeStep(10)
+			s.Expect.Retry = &retry.Config{Timeout: duration.StringDuration(time.Second), Interval: duration.StringDuration(10 * time.Millisecond)}
+			err := m.request(context.Background(), s.Request, &s.Expect, nil, nil)
+			if (err != nil) != tt.wantErr || attempts != 2 {
+				t.Errorf("request() error = %v, wantErr %v, attempts = %d", err, tt.wantErr, attempts)
+			}
+		})
+	}
+}
+
+func TestRunner_requestStrictFallback(t *testing.T) {
+	m := newChargeRunner(t, func(w http.ResponseWriter, r *http.Request, charge func(string)) {
+		ioutil.ReadAll(r.Body)
+		charge(`{"amount": 10}`)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	m.mocks.UpdateConfigs(config.MockConfigs{"billing": {Fallback: config.MockFallbackConfig{Mode: config.FallbackStrict}}})
+	m.mocks.SetStubs("billing", nil)
+
+	s := chargeStep(10)
+	s.Request.Timeout = duration.StringDuration(5 * time.Second)
+	start := time.Now()
+	err := m.request(context.Background(), s.Request, &s.Expect, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "unmatched request") {
+		t.Errorf("request() error = %v, want the unmatched request", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("request() is not failed at once: %s", d)
+	}
+}
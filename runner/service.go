@@ -1,4 +1,4 @@
-package main
+package runner
 
 import (
 	"bytes"
@@ -7,17 +7,22 @@ import (
 	"time"
 
 	"github.com/fsouza/go-dockerclient"
+
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/prettylog"
 )
 
 type Service struct {
 	Name string
+	// IsDebug prints logs of the service on stop
+	IsDebug bool
 
-	conf *ServiceConfig
+	conf *config.ServiceConfig
 
 	cntID string
 }
 
-func NewService(conf *ServiceConfig) *Service {
+func NewService(conf *config.ServiceConfig) *Service {
 	name := conf.Image
 	if i := strings.Index(name, ":"); i > 0 {
 		name = name[:i]
@@ -80,8 +85,8 @@ func (s *Service) Stop(dc *docker.Client) {
 			log.Printf("Error on stop %q service: %v", s.Name, err)
 		}
 
-		if isDebug {
-			s.PrintLogs()
+		if s.IsDebug {
+			s.PrintLogs(dc)
 		}
 
 		err = dc.RemoveContainer(docker.RemoveContainerOptions{
@@ -94,12 +99,12 @@ func (s *Service) Stop(dc *docker.Client) {
 	}
 }
 
-func (s *Service) PrintLogs() {
+func (s *Service) PrintLogs(dc *docker.Client) {
 	if s == nil || s.cntID == "" {
 		log.Print("Error on print logs: service is nil")
 		return
 	}
-	bottom := LogPrintH2Borders("Logs %q service", s.Name)
+	bottom := prettylog.H2Borders("Logs %q service", s.Name)
 
 	var bs bytes.Buffer
 
@@ -117,3 +122,31 @@ func (s *Service) PrintLogs() {
 		log.Printf("Error on show logs for %q service: %v", s.Name, err)
 	}
 }
+
+// dockerExec runs the command in the container and returns its exit code and output
+func dockerExec(dc *docker.Client, cntID string, cmd []string) (int, string, error) {
+	exec, err := dc.CreateExec(docker.CreateExecOptions{
+		Container:    cntID,
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return 0, "", err
+	}
+
+	var bs bytes.Buffer
+	err = dc.StartExec(exec.ID, docker.StartExecOptions{
+		OutputStream: &bs,
+		ErrorStream:  &bs,
+	})
+	if err != nil {
+		return 0, bs.String(), err
+	}
+
+	inspect, err := dc.InspectExec(exec.ID)
+	if err != nil {
+		return 0, bs.String(), err
+	}
+	return inspect.ExitCode, bs.String(), nil
+}
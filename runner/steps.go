@@ -0,0 +1,132 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/duration"
+	"github.com/vkd/microtest/prettylog"
+	"github.com/vkd/microtest/retry"
+	"github.com/vkd/microtest/vars"
+)
+
+// runSteps runs steps of the test in order, kind is used in logs: step, before_each, after_each
+func (m *Runner) runSteps(ctx context.Context, kind string, steps []config.StepConfig, t *config.TestConfig, vs vars.Map) error {
+	for i := range steps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s := &steps[i]
+		name := s.Name
+		if name == "" {
+			name = s.Kind()
+		}
+		log.Printf("  %s #%d: %s", kind, i+1, name)
+
+		err := m.runStep(ctx, s, t, vs)
+		if err != nil {
+			return fmt.Errorf("%s #%d (%s): %v", kind, i+1, name, err)
+		}
+	}
+	return nil
+}
+
+func (m *Runner) runStep(ctx context.Context, s *config.StepConfig, t *config.TestConfig, vs vars.Map) error {
+	if s.Sleep > 0 {
+		err := sleep(ctx, time.Duration(s.Sleep))
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.ResetMocks {
+		m.resetMocks(t)
+	}
+
+	if s.Exec != nil {
+		err := m.exec(s.Exec)
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.Wait != nil {
+		err := m.wait(ctx, s.Wait, vs)
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.Request != nil {
+		// steps of before_each and after_each are shared by tests
+		r := *s.Request
+		return m.request(ctx, &r, &s.Expect, s.Publish, vs)
+	}
+	for i := range s.Publish {
+		err := m.brokers.Publish(&s.Publish[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resetMocks reports unmatched requests and clears requests received by mocks
+func (m *Runner) resetMocks(t *config.TestConfig) {
+	_ = m.reportUnmatched()
+	m.mocks.ResetMocks(t.Mocks)
+}
+
+func (m *Runner) wait(ctx context.Context, w *config.WaitConfig, vs vars.Map) error {
+	rc := w.Config
+	if rc.Timeout == 0 {
+		rc.Timeout = duration.StringDuration(30 * time.Second)
+	}
+	if rc.Interval == 0 {
+		rc.Interval = duration.StringDuration(time.Second)
+	}
+
+	r := w.Request
+	r.OverrideByVariables(vs)
+	w.Expect.SetVars(vs)
+
+	err := retry.DoContext(ctx, &rc, func() error {
+		return m.testedService.Request(ctx, &r, &w.Expect)
+	})
+	if err != nil {
+		return fmt.Errorf("wait timeout (%s): %v", time.Duration(rc.Timeout), err)
+	}
+	return nil
+}
+
+func (m *Runner) exec(e *config.ExecConfig) error {
+	cntID := ""
+	if e.Service == "" {
+		if m.testedService.cnt != nil {
+			cntID = m.testedService.cnt.ID
+		}
+	} else if srv, ok := m.services[e.Service]; ok {
+		cntID = srv.cntID
+	}
+	if cntID == "" {
+		return fmt.Errorf("container of exec not found: %q", e.Service)
+	}
+
+	code, output, err := dockerExec(m.dc, cntID, []string{"sh", "-c", e.Command})
+	if err != nil {
+		return fmt.Errorf("exec %q: %v", e.Command, err)
+	}
+	if m.opts.Debug {
+		log.Printf("Exec output: %s", output)
+	}
+	if code != e.ExitCode {
+		prettylog.H2("Output of exec %q", e.Command)
+		log.Print(strings.TrimRight(output, "\n"))
+		return fmt.Errorf("wrong exit code of exec %q: %d (expect: %d)", e.Command, code, e.ExitCode)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package runner
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/vars"
+)
+
+func TestRunner_runSteps(t *testing.T) {
+	m := New(&config.Config{}, Options{})
+	err := m.runSteps(context.Background(), "step", []config.StepConfig{
+		{Sleep: 1},
+		{Name: "import users", Publish: []config.PublishConfig{{Broker: "events", Topic: "users"}}},
+	}, &config.TestConfig{}, vars.Map{})
+	if err == nil || !strings.HasPrefix(err.Error(), "step #2 (import users): ") {
+		t.Errorf("runSteps() error = %v", err)
+	}
+}
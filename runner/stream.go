@@ -0,0 +1,307 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/expect"
+)
+
+type stream interface {
+	// Next waits for the next message, nil on timeout
+	Next(timeout time.Duration) (*expect.Message, error)
+	Send(msg string) error
+	Close() error
+}
+
+// sendStreamRequest connects to websocket or sse endpoint, sends messages
+// and receives expected count of messages, each one waits for its timeout
+// after sending its own message (if any), so requests and responses interleave
+func sendStreamRequest(ctx context.Context, ip string, port int, conf *config.RequestConfig, exp *config.StreamExpectConfig) (*expect.Result, error) {
+	var s stream
+	var res *expect.Result
+	var err error
+	switch conf.Protocol {
+	case config.ProtocolWebSocket:
+		s, res, err = openWebSocket(ctx, ip, port, conf)
+	case config.ProtocolSSE:
+		s, res, err = openSSE(ctx, ip, port, conf)
+	default:
+		return nil, fmt.Errorf("unknown stream protocol: %q", conf.Protocol)
+	}
+	if err != nil || s == nil {
+		return res, err
+	}
+	defer s.Close()
+	// the stream is closed to stop waiting for messages when ctx is done
+	stop := context.AfterFunc(ctx, func() { s.Close() })
+	defer stop()
+
+	if exp == nil {
+		return res, nil
+	}
+	for i := range exp.Messages {
+		if send := exp.Messages[i].Send; send != "" {
+			err = s.Send(send)
+			if err != nil {
+				return nil, err
+			}
+		}
+		timeout := streamTimeout(conf, exp, i)
+		msg, err := s.Next(timeout)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if msg == nil {
+			break
+		}
+		res.Messages = append(res.Messages, msg)
+	}
+
+	bodies := make([]interface{}, 0, len(res.Messages))
+	for _, msg := range res.Messages {
+		var v interface{}
+		if json.Unmarshal(msg.Data, &v) != nil {
+			v = string(msg.Data)
+		}
+		bodies = append(bodies, v)
+	}
+	res.RawBody, err = json.Marshal(bodies)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func streamTimeout(conf *config.RequestConfig, exp *config.StreamExpectConfig, i int) time.Duration {
+	for _, d := range []time.Duration{
+		time.Duration(exp.Messages[i].Timeout),
+		time.Duration(exp.Timeout),
+		time.Duration(conf.Timeout),
+	} {
+		if d > 0 {
+			return d
+		}
+	}
+	return 3 * time.Second
+}
+
+type webSocketStream struct {
+	conn *websocket.Conn
+}
+
+func openWebSocket(ctx context.Context, ip string, port int, conf *config.RequestConfig) (stream, *expect.Result, error) {
+	header := http.Header{}
+	for k, v := range conf.Headers {
+		header.Set(k, v)
+	}
+	u := fmt.Sprintf("ws://%s:%d/%s", ip, port, strings.TrimLeft(conf.URL, "/"))
+
+	dialer := websocket.Dialer{HandshakeTimeout: 3 * time.Second}
+	conn, resp, err := dialer.DialContext(ctx, u, header)
+	if err == websocket.ErrBadHandshake && resp != nil {
+		// wrong status is checked by expect
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, &expect.Result{
+			Method:  config.ProtocolWebSocket,
+			URL:     "/" + strings.TrimLeft(conf.URL, "/"),
+			Status:  resp.StatusCode,
+			Header:  resp.Header,
+			RawBody: body,
+		}, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s := &webSocketStream{conn}
+	for _, msg := range conf.Send {
+		err = s.Send(msg)
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+	}
+
+	return s, &expect.Result{
+		Method: config.ProtocolWebSocket,
+		URL:    "/" + strings.TrimLeft(conf.URL, "/"),
+		Status: resp.StatusCode,
+		Header: resp.Header,
+	}, nil
+}
+
+func (s *webSocketStream) Next(timeout time.Duration) (*expect.Message, error) {
+	err := s.conn.SetReadDeadline(time.Now().Add(timeout))
+	if err != nil {
+		return nil, err
+	}
+	_, data, err := s.conn.ReadMessage()
+	if err != nil {
+		if ne, ok := err.(interface{ Timeout() bool }); ok && ne.Timeout() {
+			return nil, nil
+		}
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &expect.Message{Data: data}, nil
+}
+
+func (s *webSocketStream) Send(msg string) error {
+	err := s.conn.WriteMessage(websocket.TextMessage, []byte(msg))
+	if err != nil {
+		return fmt.Errorf("send websocket message: %v", err)
+	}
+	return nil
+}
+
+func (s *webSocketStream) Close() error {
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	_ = s.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	return s.conn.Close()
+}
+
+type sseStream struct {
+	cancel func()
+	events chan *expect.Message
+	errs   chan error
+}
+
+func openSSE(ctx context.Context, ip string, port int, conf *config.RequestConfig) (stream, *expect.Result, error) {
+	method := conf.Method
+	if method == "" {
+		method = "GET"
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("http://%s:%d/%s", ip, port, strings.TrimLeft(conf.URL, "/")), strings.NewReader(conf.Body))
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	for k, v := range conf.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	res := &expect.Result{
+		Method: method,
+		URL:    "/" + strings.TrimLeft(conf.URL, "/"),
+		Status: resp.StatusCode,
+		Header: resp.Header,
+	}
+	if resp.StatusCode != http.StatusOK {
+		// wrong status is checked by expect
+		res.RawBody, _ = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		cancel()
+		return nil, res, nil
+	}
+
+	s := &sseStream{
+		cancel: func() {
+			cancel()
+			resp.Body.Close()
+		},
+		events: make(chan *expect.Message),
+		errs:   make(chan error, 1),
+	}
+	go s.read(ctx, bufio.NewReader(resp.Body))
+	return s, res, nil
+}
+
+// read parses events: lines "event: name" and "data: ..." separated by an empty line
+func (s *sseStream) read(ctx context.Context, r *bufio.Reader) {
+	msg := &expect.Message{}
+	var data []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if ctx.Err() == nil {
+				s.errs <- err
+			}
+			close(s.events)
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			if len(data) == 0 {
+				continue
+			}
+			msg.Data = []byte(strings.Join(data, "\n"))
+			select {
+			case s.events <- msg:
+			case <-ctx.Done():
+				return
+			}
+			msg, data = &expect.Message{}, nil
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "event":
+			msg.Event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+}
+
+func (s *sseStream) Next(timeout time.Duration) (*expect.Message, error) {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+
+	select {
+	case msg, ok := <-s.events:
+		if !ok {
+			select {
+			case err := <-s.errs:
+				if err == io.EOF {
+					return nil, nil
+				}
+				return nil, err
+			default:
+				return nil, nil
+			}
+		}
+		return msg, nil
+	case <-t.C:
+		return nil, nil
+	}
+}
+
+func (s *sseStream) Send(msg string) error {
+	return fmt.Errorf("sse stream does not send messages: %q", msg)
+}
+
+func (s *sseStream) Close() error {
+	s.cancel()
+	return nil
+}
@@ -0,0 +1,175 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/expect"
+)
+
+func testServerAddr(t *testing.T, srv *httptest.Server) (string, int) {
+	host, portStr, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.Atoi(portStr)
+	return host, port
+}
+
+func TestSendStreamRequest_WebSocket(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"type": "ack", "request": `+string(msg)+`}`))
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"type": "price", "value": 2}`))
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"type": "price", "value": 1}`))
+		_, _, _ = conn.ReadMessage()
+	}))
+	defer srv.Close()
+	ip, port := testServerAddr(t, srv)
+
+	tests := []struct {
+		name    string
+		exp     *config.StreamExpectConfig
+		wantErr bool
+	}{
+		{"ordered", &config.StreamExpectConfig{Messages: []config.StreamMessageConfig{
+			{ExpectConfig: config.ExpectConfig{BodyMin: `{"type": "ack", "request": {"id": 1}}`}},
+			{ExpectConfig: config.ExpectConfig{Body: `{"type": "price", "value": 2}`}},
+			{ExpectConfig: config.ExpectConfig{Body: `{"type": "price", "value": 1}`}},
+		}}, false},
+		{"wrong order", &config.StreamExpectConfig{Messages: []config.StreamMessageConfig{
+			{ExpectConfig: config.ExpectConfig{BodyMin: `{"type": "ack"}`}},
+			{ExpectConfig: config.ExpectConfig{Body: `{"type": "price", "value": 1}`}},
+		}}, true},
+		{"unordered", &config.StreamExpectConfig{Mode: config.StreamUnordered, Messages: []config.StreamMessageConfig{
+			{ExpectConfig: config.ExpectConfig{Body: `{"type": "price", "value": 1}`}},
+			{ExpectConfig: config.ExpectConfig{Body: `{"type": "price", "value": 2}`}},
+			{ExpectConfig: config.ExpectConfig{BodyMin: `{"type": "ack"}`}},
+		}}, false},
+		{"unordered broad first", &config.StreamExpectConfig{Mode: config.StreamUnordered, Messages: []config.StreamMessageConfig{
+			{ExpectConfig: config.ExpectConfig{BodyMin: `{"type": "price"}`}},
+			{ExpectConfig: config.ExpectConfig{Body: `{"type": "price", "value": 2}`}},
+			{ExpectConfig: config.ExpectConfig{BodyMin: `{"type": "ack"}`}},
+		}}, false},
+		{"unordered not found", &config.StreamExpectConfig{Mode: config.StreamUnordered, Messages: []config.StreamMessageConfig{
+			{ExpectConfig: config.ExpectConfig{BodyMin: `{"type": "price"}`}},
+			{ExpectConfig: config.ExpectConfig{Body: `{"type": "price", "value": 3}`}},
+		}}, true},
+		{"timeout", &config.StreamExpectConfig{Timeout: 50 * 1e6, Messages: []config.StreamMessageConfig{
+			{ExpectConfig: config.ExpectConfig{BodyMin: `{"type": "ack"}`}},
+			{ExpectConfig: config.ExpectConfig{BodyMin: `{"type": "price"}`}},
+			{ExpectConfig: config.ExpectConfig{BodyMin: `{"type": "price"}`}},
+			{ExpectConfig: config.ExpectConfig{BodyMin: `{"type": "price"}`}},
+		}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := sendStreamRequest(context.Background(), ip, port, &config.RequestConfig{
+				Protocol: config.ProtocolWebSocket,
+				URL:      "/ws",
+				Send:     []string{`{"id": 1}`},
+			}, tt.exp)
+			if err != nil {
+				t.Fatalf("sendStreamRequest() error = %v", err)
+			}
+			err = expect.New().Check(res, &config.ExpectConfig{Stream: tt.exp})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSendStreamRequest_WebSocketInterleaved(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"reply": `+string(msg)+`}`))
+		}
+	}))
+	defer srv.Close()
+	ip, port := testServerAddr(t, srv)
+
+	exp := &config.StreamExpectConfig{Messages: []config.StreamMessageConfig{
+		{Send: `{"id": 1}`, ExpectConfig: config.ExpectConfig{Body: `{"reply": {"id": 1}}`}},
+		{Send: `{"id": 2}`, ExpectConfig: config.ExpectConfig{Body: `{"reply": {"id": 2}}`}},
+	}}
+	res, err := sendStreamRequest(context.Background(), ip, port, &config.RequestConfig{Protocol: config.ProtocolWebSocket, URL: "/ws"}, exp)
+	if err != nil {
+		t.Fatalf("sendStreamRequest() error = %v", err)
+	}
+	if err = expect.New().Check(res, &config.ExpectConfig{Stream: exp}); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+}
+
+func TestSendStreamRequest_SSE(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/events" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": comment\n\nevent: created\ndata: {\"id\": 1}\n\n")
+		w.(http.Flusher).Flush()
+		time.Sleep(10 * time.Millisecond)
+		fmt.Fprint(w, "event: deleted\r\ndata: {\"id\":\r\ndata: 1}\r\n\r\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	ip, port := testServerAddr(t, srv)
+
+	exp := &config.StreamExpectConfig{Messages: []config.StreamMessageConfig{
+		{Event: "created", ExpectConfig: config.ExpectConfig{Body: `{"id": 1}`}},
+		{Event: "deleted", ExpectConfig: config.ExpectConfig{Body: `{"id": 1}`}},
+	}}
+	res, err := sendStreamRequest(context.Background(), ip, port, &config.RequestConfig{Protocol: config.ProtocolSSE, URL: "/events"}, exp)
+	if err != nil {
+		t.Fatalf("sendStreamRequest() error = %v", err)
+	}
+	err = expect.New().Check(res, &config.ExpectConfig{Stream: exp})
+	if err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+
+	send := &config.StreamExpectConfig{Messages: []config.StreamMessageConfig{{Send: `{"id": 1}`}}}
+	if _, err = sendStreamRequest(context.Background(), ip, port, &config.RequestConfig{Protocol: config.ProtocolSSE, URL: "/events"}, send); err == nil {
+		t.Errorf("sendStreamRequest() with send to sse error = nil")
+	}
+
+	res, err = sendStreamRequest(context.Background(), ip, port, &config.RequestConfig{Protocol: config.ProtocolSSE, URL: "/unknown"}, exp)
+	if err != nil {
+		t.Fatalf("sendStreamRequest() error = %v", err)
+	}
+	if err = expect.New().Check(res, &config.ExpectConfig{Stream: exp}); err != expect.ErrWrongStatus {
+		t.Errorf("Check() error = %v, want %v", err, expect.ErrWrongStatus)
+	}
+}
@@ -0,0 +1,311 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/contract"
+	"github.com/vkd/microtest/duration"
+	"github.com/vkd/microtest/expect"
+	"github.com/vkd/microtest/prettylog"
+	"github.com/vkd/microtest/protos"
+	"github.com/vkd/microtest/template"
+)
+
+type TestedService struct {
+	// IsDebug enables verbose logs of the tested service
+	IsDebug bool
+
+	dc   *docker.Client
+	conf *config.Config
+	cnt  *docker.Container
+	spec *contract.Spec
+
+	protos *protos.Files
+
+	ip       string
+	port     int
+	grpcPort int
+}
+
+func NewTestedService(dc *docker.Client, conf *config.Config, port int) *TestedService {
+	t := &TestedService{
+		dc:   dc,
+		conf: conf,
+		port: port,
+	}
+
+	if t.port == 0 {
+		t.port = 9000
+	}
+	t.grpcPort = conf.GRPCPort
+	if t.grpcPort == 0 {
+		t.grpcPort = t.port
+	}
+
+	return t
+}
+
+type RunConfig struct {
+	MocksPort  int
+	SelfIP     string
+	Workdir    string
+	ExtraHosts []string
+}
+
+func (t *TestedService) Run(mc *RunConfig) error {
+	if t.conf.Image == "" {
+		return errors.New("'image' not found in config")
+	}
+
+	if t.conf.OpenAPI != "" {
+		spec, err := contract.Load(t.conf.OpenAPI)
+		if err != nil {
+			log.Printf("Error on load openapi spec (%s): %v", t.conf.OpenAPI, err)
+			return err
+		}
+		t.spec = spec
+	}
+
+	if t.conf.Proto != "" {
+		files, err := protos.Load(t.conf.Proto)
+		if err != nil {
+			log.Printf("Error on load proto (%s): %v", t.conf.Proto, err)
+			return err
+		}
+		t.protos = files
+	}
+
+	for mockName := range t.conf.Mocks {
+		mc.ExtraHosts = append(mc.ExtraHosts, fmt.Sprintf("%s: %s", mockName, mc.SelfIP))
+	}
+	for mockName := range t.conf.ProtocolMocks {
+		mc.ExtraHosts = append(mc.ExtraHosts, fmt.Sprintf("%s: %s", mockName, mc.SelfIP))
+	}
+
+	// var links []string
+	// for mockName := range t.conf.Mocks {
+	// 	links = append(links, fmt.Sprintf("%s:%s", mockName, mockName))
+	// }
+
+	if t.IsDebug {
+		log.Printf("Extra hosts: %v", mc.ExtraHosts)
+		// log.Printf("Links: %v", links)
+	}
+
+	var binds []string
+	if mc.Workdir != "" {
+		binds = append(binds, fmt.Sprintf("%s:/builds/localhost/microtests:ro", mc.Workdir))
+	}
+
+	cnt, err := t.dc.CreateContainer(docker.CreateContainerOptions{
+		Config: &docker.Config{
+			Image: t.conf.Image,
+			Cmd: strings.Fields(template.StringDefault(t.conf.Command,
+				template.D{
+					"workdir": "/builds/localhost",
+				})),
+		},
+		HostConfig: &docker.HostConfig{
+			Binds:      binds,
+			ExtraHosts: mc.ExtraHosts,
+			// Links:      mc.Links,
+		},
+	})
+	if err != nil {
+		log.Printf("Error on create tested %q container: %v", t.conf.Image, err)
+		return err
+	}
+
+	err = t.dc.StartContainer(cnt.ID, &docker.HostConfig{})
+	if err != nil {
+		log.Printf("Error on start tested %q container: %v", t.conf.Image, err)
+		return err
+	}
+
+	cnt, err = t.dc.InspectContainer(cnt.ID)
+	if err != nil {
+		log.Printf("Error on inspect %q container: %v", t.conf.Image, err)
+		return err
+	}
+
+	t.cnt = cnt
+
+	t.ip = cnt.NetworkSettings.IPAddress
+	if t.IsDebug {
+		log.Printf("Tested service (%s) started on ip: %s", t.conf.Image, t.ip)
+	}
+	return nil
+}
+
+func (t *TestedService) Stop() error {
+	if t.cnt == nil {
+		return nil
+	}
+	err := t.dc.StopContainer(t.cnt.ID, 3)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *TestedService) Remove() error {
+	if t.cnt != nil {
+		err := t.dc.RemoveContainer(docker.RemoveContainerOptions{
+			ID:    t.cnt.ID,
+			Force: true,
+		})
+		if err != nil {
+			log.Printf("Error on remove %q container", t.conf.Image)
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *TestedService) Request(ctx context.Context, r *config.RequestConfig, ex *config.ExpectConfig) error {
+	if r == nil {
+		return nil
+	}
+
+	res, err := t.send(ctx, r, streamExpect(r.Expect, ex))
+	if err != nil {
+		log.Printf("Error on send request to (%s): %v", t.ip, err)
+		return err
+	}
+
+	if t.spec != nil && r.Protocol != config.ProtocolGRPC {
+		header := http.Header{}
+		for k, v := range r.Headers {
+			header.Set(k, v)
+		}
+		err = t.spec.ValidateResponse(
+			&contract.Request{Method: res.Method, URL: res.URL, Header: header, Body: []byte(r.Body)},
+			&contract.Response{Status: res.Status, Header: res.Header, Body: res.RawBody},
+		)
+		if err != nil {
+			log.Printf("Error on check contract: %v", err)
+			return err
+		}
+	}
+
+	err = expect.New().Check(res, r.Expect)
+	if err != nil {
+		log.Printf("Error on check request expect: %v", err)
+		return err
+	}
+
+	err = expect.New().Check(res, ex)
+	if err != nil {
+		log.Printf("Error on check expect: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (t *TestedService) send(ctx context.Context, r *config.RequestConfig, stream *config.StreamExpectConfig) (*expect.Result, error) {
+	switch r.Protocol {
+	case "", config.ProtocolHTTP:
+		return sendRequest(ctx, t.ip, t.port, r)
+	case config.ProtocolGRPC:
+		return sendGRPCRequest(ctx, t.protos, t.ip, t.grpcPort, r)
+	case config.ProtocolWebSocket, config.ProtocolSSE:
+		return sendStreamRequest(ctx, t.ip, t.port, r, stream)
+	}
+	return nil, fmt.Errorf("unknown request protocol: %q", r.Protocol)
+}
+
+// streamExpect returns the longest expected messages of a stream,
+// they define how many messages are received
+func streamExpect(exs ...*config.ExpectConfig) *config.StreamExpectConfig {
+	var out *config.StreamExpectConfig
+	for _, ex := range exs {
+		if ex != nil && ex.Stream != nil {
+			if out == nil || len(ex.Stream.Messages) > len(out.Messages) {
+				out = ex.Stream
+			}
+		}
+	}
+	return out
+}
+
+func (t *TestedService) PingRequest(ctx context.Context, r *config.PingRequestConfig) error {
+	if r == nil {
+		return nil
+	}
+	if time.Duration(r.Timeout) == 0 {
+		r.Timeout = duration.StringDuration(time.Second)
+	}
+
+	if r.Count == 0 {
+		r.Count = 3
+	}
+
+	if r.URL == "" {
+		r.URL = "/ping"
+	}
+
+	var err error
+	for i := 0; i < r.Count; i++ {
+		if i > 0 {
+			if err := sleep(ctx, time.Second); err != nil {
+				return err
+			}
+		}
+
+		res, err := t.send(ctx, &r.RequestConfig, nil)
+		if err != nil {
+			if t.IsDebug {
+				log.Printf("Error on send ping request to (%s): %v", t.ip, err)
+			}
+			continue
+		}
+
+		err = expect.New().Check(res, &config.ExpectConfig{Status: 200})
+		if err != nil {
+			if t.IsDebug {
+				log.Printf("Error on check ping request expect: %v", err)
+			}
+			continue
+		}
+
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (t *TestedService) PrintLogs() {
+	if t == nil || t.cnt == nil {
+		log.Print("Error on print logs: tested service is nil")
+		return
+	}
+	bottom := prettylog.H2Borders("Logs tested service %q", t.conf.Image)
+
+	var bs bytes.Buffer
+
+	err := t.dc.Logs(docker.LogsOptions{
+		Container: t.cnt.ID,
+		Stdout:    true,
+		Stderr:    true,
+
+		OutputStream: &bs,
+		ErrorStream:  &bs,
+	})
+	log.Print(bs.String())
+	bottom()
+	if err != nil {
+		log.Printf("Error on show logs for %s: %v", t.conf.Image, err)
+	}
+}
@@ -0,0 +1,94 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+
+	"github.com/vkd/microtest/cmp"
+)
+
+const inlineURL = "inline.json"
+
+type Schema struct {
+	s *jsonschema.Schema
+}
+
+// Compile compiles an inline JSON Schema (draft 2020-12 by default).
+func Compile(src []byte) (*Schema, error) {
+	c := newCompiler()
+	err := c.AddResource(inlineURL, bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	s, err := c.Compile(inlineURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{s}, nil
+}
+
+// CompileFile compiles a JSON Schema file, relative "$ref"s are resolved from its directory.
+func CompileFile(path string) (*Schema, error) {
+	s, err := newCompiler().Compile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{s}, nil
+}
+
+func newCompiler() *jsonschema.Compiler {
+	c := jsonschema.NewCompiler()
+	c.Draft = jsonschema.Draft2020
+	return c
+}
+
+// Validate checks a JSON document, the error is a *cmp.ErrCmp pointing to the wrong value.
+func (s *Schema) Validate(body []byte) error {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	err := dec.Decode(&v)
+	if err != nil {
+		return err
+	}
+	return s.ValidateValue(v)
+}
+
+func (s *Schema) ValidateValue(v interface{}) error {
+	err := s.s.Validate(v)
+	if err == nil {
+		return nil
+	}
+	ve, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return err
+	}
+	for len(ve.Causes) > 0 {
+		ve = ve.Causes[0]
+	}
+	return newErrCmp(ve.InstanceLocation, errors.New(ve.Message))
+}
+
+// newErrCmp converts an instance location ("/items/0/id") to a chain of *cmp.ErrCmp.
+func newErrCmp(location string, err error) *cmp.ErrCmp {
+	location = strings.TrimPrefix(location, "/")
+	if location == "" {
+		return cmp.NewErrCmpField(cmp.RootPath, err)
+	}
+
+	fields := strings.Split(location, "/")
+	for i := len(fields) - 1; i >= 0; i-- {
+		f := strings.NewReplacer("~1", "/", "~0", "~").Replace(fields[i])
+		if idx, errAtoi := strconv.Atoi(f); errAtoi == nil {
+			err = cmp.NewErrCmpIndex(idx, err)
+		} else {
+			err = cmp.NewErrCmpField(f, err)
+		}
+	}
+	return err.(*cmp.ErrCmp)
+}
@@ -0,0 +1,43 @@
+package schema
+
+import "testing"
+
+func TestSchema_Validate(t *testing.T) {
+	const userSchema = `{
+		"type": "object",
+		"required": ["id", "tags"],
+		"properties": {
+			"id": {"type": "integer"},
+			"tags": {"type": "array", "items": {"type": "string"}}
+		}
+	}`
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{"valid", `{"id": 1, "tags": ["a"]}`, ""},
+		{"missing field", `{"id": 1}`, `not equals "$": missing properties: 'tags'`},
+		{"wrong item type", `{"id": 1, "tags": ["a", 2]}`, `not equals "tags.[1]": expected string, but got number`},
+	}
+
+	s, err := Compile([]byte(userSchema))
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.Validate([]byte(tt.body))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Schema.Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Schema.Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
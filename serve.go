@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/vkd/microtest/config"
+	"github.com/vkd/microtest/mock"
+)
+
+const (
+	// reloadInterval is a period of checks of changes of the config served by `mock serve`
+	reloadInterval = time.Second
+)
+
+// serveMocks serves mocks of the config and their admin api until ctx is done,
+// mocks are reloaded when the config is changed
+func serveMocks(ctx context.Context, configPath string) error {
+	port, err := parsePort("--port", mocksPort, mock.DefaultMocksPort)
+	if err != nil {
+		return err
+	}
+	// the admin api listens on the next port, a random one for a random port of mocks
+	defAdmin := port + 1
+	if port == 0 {
+		defAdmin = 0
+	}
+	admin, err := parsePort("--admin-port", adminPort, defAdmin)
+	if err != nil {
+		return err
+	}
+
+	conf, err := readMocksConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	m := mock.NewMocks(conf.Mocks)
+	m.IsDebug = isDebug
+	m.Port = port
+	err = m.LoadSpecs(conf.Mocks)
+	if err != nil {
+		return err
+	}
+	m.ResetMocks(nil)
+	err = m.Run()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := m.Stop()
+		if err != nil {
+			log.Printf("Error on stop mocks: %v", err)
+		}
+	}()
+
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", admin))
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: m.AdminHandler()}
+	go func() {
+		err := srv.Serve(ln)
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("Error on serve admin api: %v", err)
+		}
+	}()
+	defer srv.Close()
+
+	log.Printf("Mocks of %s listen port: %d, admin api: %d", configPath, m.Port, ln.Addr().(*net.TCPAddr).Port)
+
+	modTimes := fileModTimes(conf.Files())
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if !modTimes.changed() {
+			continue
+		}
+
+		conf, err := readMocksConfig(configPath)
+		if err == nil {
+			err = m.Reload(conf.Mocks)
+		}
+		if err != nil {
+			// changed files are checked again on the next change
+			modTimes = fileModTimes(append(modTimes.files(), configPath))
+			log.Printf("Error on reload mocks (%s): %v", configPath, err)
+			continue
+		}
+		modTimes = fileModTimes(conf.Files())
+		log.Printf("Mocks reloaded: %s", configPath)
+	}
+}
+
+// readMocksConfig reads the config with recorded stubs of mock hosts
+func readMocksConfig(configPath string) (*config.Config, error) {
+	conf, err := config.Read(configPath, env)
+	if err != nil {
+		return nil, err
+	}
+	secrets.Add(conf.SecretValues()...)
+	err = conf.LoadReplays(false)
+	if err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// modTimes are modification times of files by their paths
+type modTimes map[string]time.Time
+
+func fileModTimes(paths []string) modTimes {
+	out := modTimes{}
+	for _, path := range paths {
+		out[path] = fileModTime(path)
+	}
+	return out
+}
+
+// changed reports whether one of files is changed, created or removed
+func (mt modTimes) changed() bool {
+	for path, t := range mt {
+		if !fileModTime(path).Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (mt modTimes) files() []string {
+	out := make([]string, 0, len(mt))
+	for path := range mt {
+		out = append(out, path)
+	}
+	return out
+}
+
+func fileModTime(path string) time.Time {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return stat.ModTime()
+}
+
+func parsePort(flag, value string, def int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 0 || port > 65535 {
+		return 0, fmt.Errorf("wrong %s: %q", flag, value)
+	}
+	return port, nil
+}
@@ -0,0 +1,44 @@
+package template
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Helpers returns functions for dynamic values:
+// {{uuid}}, {{now}} or {{now "2006-01-02"}}, {{json .value}}
+func Helpers() Funcs {
+	return Funcs{
+		"uuid": newUUID,
+		"now":  now,
+		"json": toJSON,
+	}
+}
+
+func newUUID() (string, error) {
+	var b [16]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+func now(layout ...string) string {
+	if len(layout) > 0 {
+		return time.Now().Format(layout[0])
+	}
+	return time.Now().Format(time.RFC3339)
+}
+
+func toJSON(v interface{}) (string, error) {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
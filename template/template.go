@@ -7,8 +7,20 @@ import (
 
 type D map[string]string
 
+type Funcs map[string]interface{}
+
 func String(s string, d D) (string, error) {
-	t := template.New("string").Option("missingkey=error")
+	return Execute(s, d)
+}
+
+// Execute renders the template with any data, missing keys are errors
+func Execute(s string, data interface{}) (string, error) {
+	return ExecuteFuncs(s, data, nil)
+}
+
+// ExecuteFuncs renders the template with additional functions
+func ExecuteFuncs(s string, data interface{}, funcs Funcs) (string, error) {
+	t := template.New("string").Option("missingkey=error").Funcs(template.FuncMap(funcs))
 	// t = t.Delims("{", "}")
 
 	t, err := t.Parse(s)
@@ -17,7 +29,7 @@ func String(s string, d D) (string, error) {
 	}
 
 	var bs bytes.Buffer
-	err = t.Execute(&bs, d)
+	err = t.Execute(&bs, data)
 	if err != nil {
 		return "", err
 	}
@@ -1,6 +1,9 @@
 package template
 
-import "testing"
+import (
+	"regexp"
+	"testing"
+)
 
 func TestString(t *testing.T) {
 	type args struct {
@@ -35,3 +38,27 @@ func TestString(t *testing.T) {
 		})
 	}
 }
+
+func TestExecuteFuncs_Helpers(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		data    interface{}
+		pattern string
+	}{
+		{"uuid", "{{uuid}}", nil, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{"now layout", `{{now "2006"}}`, nil, `^\d{4}$`},
+		{"json", `{{json .user}}`, map[string]interface{}{"user": map[string]interface{}{"id": 1}}, `^\{"id":1\}$`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExecuteFuncs(tt.s, tt.data, Helpers())
+			if err != nil {
+				t.Fatalf("ExecuteFuncs() error = %v", err)
+			}
+			if !regexp.MustCompile(tt.pattern).MatchString(got) {
+				t.Errorf("ExecuteFuncs() = %v, want match %v", got, tt.pattern)
+			}
+		})
+	}
+}